@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"fmt"
+	"movies-backend/models"
+	"movies-backend/utils"
+	"movies-backend/utils/token"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTimeParam accepts either a full RFC3339 timestamp or a plain YYYY-MM-DD date
+func parseTimeParam(value string) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(utils.YYYYMMDD, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q, expected RFC3339 or YYYY-MM-DD", value)
+	}
+
+	return &t, nil
+}
+
+func (h *Handler) GetActivity(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := models.ActivityFilter{Action: c.Query("action")}
+
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = parseTimeParam(from); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = parseTimeParam(to); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	activities, err := h.Movies.GetActivity(userId, filter)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, activities)
+}
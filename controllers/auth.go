@@ -9,7 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CurrentUser(c *gin.Context) {
+func (h *Handler) CurrentUser(c *gin.Context) {
 
 	userId, err := token.ExtractTokenID(c)
 
@@ -18,7 +18,7 @@ func CurrentUser(c *gin.Context) {
 		return
 	}
 
-	u, err := models.GetUserByID(userId)
+	u, err := h.Users.GetUserByID(userId)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -33,7 +33,7 @@ type LoginInput struct {
 	Password string `form:"password" binding:"required"`
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 
 	var input LoginInput
 
@@ -47,7 +47,7 @@ func Login(c *gin.Context) {
 	u.Email = input.Email
 	u.Password = input.Password
 
-	jwt, user, err := models.LoginCheck(u.Email, u.Password)
+	jwt, user, err := h.Users.LoginCheck(u.Email, u.Password)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "email or password is incorrect."})
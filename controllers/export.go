@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"movies-backend/library"
+	"movies-backend/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportLibrary streams the library as csv, json or letterboxd. The letterboxd format exports
+// the watched movies unless list=watchlist is given.
+func (h *Handler) ExportLibrary(c *gin.Context) {
+
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+
+	contentType, extension, err := library.ExportContentType(format)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	watchlist := c.Query("list") == "watchlist"
+
+	filename := fmt.Sprintf("movies-%s.%s", format, extension)
+	if watchlist {
+		filename = fmt.Sprintf("movies-%s-watchlist.%s", format, extension)
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent so a failure can only cut the stream short
+	if err := library.Export(c.Writer, h.Movies, userId, format, watchlist); err != nil {
+		log.Println("Error exporting library", userId, err)
+	}
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"movies-backend/providers"
+	"movies-backend/repository"
+)
+
+// Handler serves the API from the repositories and the metadata provider it is given
+type Handler struct {
+	Users    repository.UserRepository
+	Movies   repository.MovieRepository
+	Series   repository.SeriesRepository
+	Metadata providers.Provider
+}
+
+func NewHandler(users repository.UserRepository, movies repository.MovieRepository, series repository.SeriesRepository, metadata providers.Provider) *Handler {
+	return &Handler{Users: users, Movies: movies, Series: series, Metadata: metadata}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"movies-backend/library"
+	"movies-backend/utils"
+	"movies-backend/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportLibrary accepts any number of Letterboxd or IMDb CSV exports in a multipart form
+func (h *Handler) ImportLibrary(c *gin.Context) {
+
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	form, err := c.MultipartForm()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := library.NewImportReport()
+	files := 0
+
+	for _, headers := range form.File {
+		for _, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// A file that cannot be read is reported without losing what the other files imported
+			if err := library.ImportCSV(h.Movies, h.Metadata, userId, header.Filename, file, report); err != nil {
+				report.Failed = append(report.Failed, library.ImportProblem{File: header.Filename, Error: err.Error()})
+			}
+			file.Close()
+			files++
+		}
+	}
+
+	if files == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no CSV file uploaded"})
+		return
+	}
+
+	if report.Created > 0 || report.Restored > 0 || report.Updated > 0 {
+		go utils.TriggerModelRetrain()
+		utils.ClearUserMovieSuggestionCache(userId)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"movies-backend/library"
+	"movies-backend/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LibrarySearchInput struct {
+	Query string `form:"q" binding:"required"`
+	Limit int    `form:"limit"`
+}
+
+func (h *Handler) SearchLibrary(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input LibrarySearchInput
+
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Default to 20 results when limit is not provided or invalid
+	limit := 20
+	if input.Limit > 0 {
+		limit = input.Limit
+	}
+
+	hits, err := library.Search(h.Movies, userId, input.Query, limit)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, hits)
+}
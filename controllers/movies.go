@@ -13,7 +13,7 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-func GetWatchlist(c *gin.Context) {
+func (h *Handler) GetWatchlist(c *gin.Context) {
 	userId, err := token.ExtractTokenID(c)
 
 	if err != nil {
@@ -21,7 +21,7 @@ func GetWatchlist(c *gin.Context) {
 		return
 	}
 
-	wl, err := models.GetWatchlistByUserID(userId)
+	wl, err := h.Movies.GetWatchlist(userId, models.LibraryFilter{Genre: c.Query("genre")})
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -31,7 +31,7 @@ func GetWatchlist(c *gin.Context) {
 	c.JSON(http.StatusOK, wl)
 }
 
-func GetMovies(c *gin.Context) {
+func (h *Handler) GetMovies(c *gin.Context) {
 	userId, err := token.ExtractTokenID(c)
 
 	if err != nil {
@@ -39,7 +39,7 @@ func GetMovies(c *gin.Context) {
 		return
 	}
 
-	wl, err := models.GetMoviesByUserID(userId)
+	wl, err := h.Movies.GetMovies(userId, models.LibraryFilter{Genre: c.Query("genre")})
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -55,7 +55,7 @@ type WatchlistInput struct {
 	Image   string `json:"image"`
 }
 
-func AddToWatchlist(c *gin.Context) {
+func (h *Handler) AddToWatchlist(c *gin.Context) {
 
 	userId, err := token.ExtractTokenID(c)
 
@@ -79,7 +79,7 @@ func AddToWatchlist(c *gin.Context) {
 	wl.UserID = userId
 	wl.ReleaseDate = nil
 
-	newMovie, err := wl.SaveMovieToWatchlist()
+	newMovie, err := h.Movies.AddToWatchlist(wl)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -92,7 +92,73 @@ func AddToWatchlist(c *gin.Context) {
 	c.JSON(http.StatusCreated, newMovie)
 }
 
-func DeleteFromWatchlist(c *gin.Context) {
+type ReorderInput struct {
+	Moves []models.WatchlistMove `json:"moves" binding:"required,min=1"`
+}
+
+func (h *Handler) ReorderWatchlist(c *gin.Context) {
+
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input ReorderInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Movies.ReorderWatchlist(userId, input.Moves); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrMovieNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrStaleMovie):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrMovieNotInWatchlist):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	wl, err := h.Movies.GetWatchlist(userId, models.LibraryFilter{})
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, wl)
+}
+
+// VersionInput is the version of the entry the client last read. Writing an entry changed since
+// then fails with a conflict, leaving the version out skips the check.
+type VersionInput struct {
+	Version uint `form:"version" json:"version"`
+}
+
+// bindVersion reads the version from the JSON body, or from the query when there is no body
+func bindVersion(c *gin.Context) (uint, error) {
+	var input VersionInput
+	var err error
+
+	if c.Request.ContentLength > 0 {
+		err = c.ShouldBindJSON(&input)
+	} else {
+		err = c.ShouldBindQuery(&input)
+	}
+
+	return input.Version, err
+}
+
+func (h *Handler) DeleteFromWatchlist(c *gin.Context) {
 
 	userId, err := token.ExtractTokenID(c)
 
@@ -103,12 +169,21 @@ func DeleteFromWatchlist(c *gin.Context) {
 
 	id := c.Param("id")
 
-	if err := models.DeleteMovieFromWatchlistByID(id, userId); err != nil {
+	version, err := bindVersion(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Movies.DeleteFromWatchlist(id, userId, version); err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		case errors.Is(err, models.ErrMovieNotOwned):
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrStaleMovie):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		default:
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
@@ -121,7 +196,25 @@ func DeleteFromWatchlist(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
-func MarkMovieAsDownloaded(c *gin.Context) {
+func (h *Handler) GetTrash(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trash, err := h.Movies.GetTrash(userId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+func (h *Handler) RestoreFromTrash(c *gin.Context) {
 
 	userId, err := token.ExtractTokenID(c)
 
@@ -132,12 +225,63 @@ func MarkMovieAsDownloaded(c *gin.Context) {
 
 	id := c.Param("id")
 
-	if err := models.MarkMovieAsDownloadedByID(id, userId); err != nil {
+	version, err := bindVersion(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movie, err := h.Movies.RestoreFromTrash(id, userId, version)
+
+	if err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		case errors.Is(err, models.ErrMovieNotOwned):
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrStaleMovie):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrMovieNotInTrash):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	go utils.TriggerModelRetrain()
+	utils.ClearUserMovieSuggestionCache(userId)
+
+	c.JSON(http.StatusOK, movie)
+}
+
+func (h *Handler) MarkMovieAsDownloaded(c *gin.Context) {
+
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+
+	version, err := bindVersion(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Movies.MarkAsDownloaded(id, userId, version); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrMovieNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrStaleMovie):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		default:
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
@@ -147,7 +291,7 @@ func MarkMovieAsDownloaded(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
-func MarkMovieAsWatched(c *gin.Context) {
+func (h *Handler) MarkMovieAsWatched(c *gin.Context) {
 
 	userId, err := token.ExtractTokenID(c)
 
@@ -158,12 +302,21 @@ func MarkMovieAsWatched(c *gin.Context) {
 
 	id := c.Param("id")
 
-	if err := models.MarkMovieAsWatchedByID(id, userId); err != nil {
+	version, err := bindVersion(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Movies.MarkAsWatched(id, userId, version); err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		case errors.Is(err, models.ErrMovieNotOwned):
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrStaleMovie):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		default:
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
@@ -174,10 +327,11 @@ func MarkMovieAsWatched(c *gin.Context) {
 }
 
 type RatingInput struct {
-	Rating uint `json:"rating" binding:"required"`
+	Rating  uint `json:"rating" binding:"required"`
+	Version uint `json:"version"`
 }
 
-func RateMovie(c *gin.Context) {
+func (h *Handler) RateMovie(c *gin.Context) {
 
 	userId, err := token.ExtractTokenID(c)
 
@@ -195,12 +349,14 @@ func RateMovie(c *gin.Context) {
 		return
 	}
 
-	if err := models.RateMovieByID(id, userId, input.Rating); err != nil {
+	if err := h.Movies.Rate(id, userId, input.Rating, input.Version); err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		case errors.Is(err, models.ErrMovieNotOwned):
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrStaleMovie):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		default:
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"movies-backend/models"
+	"movies-backend/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetPreferences(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := h.Users.GetPreferences(userId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// PreferencesInput replaces the release preferences. The language is kept when left out and an
+// empty language restores the default.
+type PreferencesInput struct {
+	Regions      []string `json:"regions"`
+	ReleaseTypes []int    `json:"release_types"`
+	Language     *string  `json:"language" binding:"omitempty,eq=|bcp47_language_tag"`
+}
+
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input PreferencesInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := h.Users.UpdatePreferences(userId, models.PreferencesUpdate{
+		ReleasePreferences: models.ReleasePreferences{Regions: input.Regions, ReleaseTypes: input.ReleaseTypes},
+		Language:           input.Language,
+	})
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
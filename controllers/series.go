@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"errors"
+	"movies-backend/models"
+	"movies-backend/providers"
+	"movies-backend/utils/token"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// seriesError responds with the status matching an error of the series model
+func seriesError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrSeriesNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrSeriesAlreadyTracked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, providers.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+type SeriesSearchInput struct {
+	Term string `form:"term" binding:"required"`
+}
+
+func (h *Handler) SearchForSeries(c *gin.Context) {
+	_, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input SeriesSearchInput
+
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := h.Metadata.SearchSeries(input.Term, providers.SearchOptions{Language: "en-US", Page: 1})
+
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func (h *Handler) GetSeries(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := h.Series.GetSeries(userId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+type SeriesInput struct {
+	SeriesId uint `json:"series_id" binding:"required"`
+}
+
+func (h *Handler) AddSeries(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input SeriesInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := h.Series.AddSeries(userId, input.SeriesId)
+
+	if err != nil {
+		seriesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, series)
+}
+
+func (h *Handler) GetSeriesDetails(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := h.Series.GetSeriesByID(c.Param("id"), userId)
+
+	if err != nil {
+		seriesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func (h *Handler) DeleteSeries(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Series.DeleteSeries(c.Param("id"), userId); err != nil {
+		seriesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *Handler) GetNextEpisode(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	episode, err := h.Series.GetNextEpisode(c.Param("id"), userId)
+
+	if err != nil {
+		seriesError(c, err)
+		return
+	}
+
+	if episode == nil {
+		c.JSON(http.StatusNoContent, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, episode)
+}
+
+func (h *Handler) MarkEpisodeAsWatched(c *gin.Context) {
+	h.markEpisode(c, true)
+}
+
+func (h *Handler) UnmarkEpisodeAsWatched(c *gin.Context) {
+	h.markEpisode(c, false)
+}
+
+func (h *Handler) markEpisode(c *gin.Context, watched bool) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Series.MarkEpisodeAsWatched(c.Param("id"), userId, c.Param("episodeId"), watched); err != nil {
+		seriesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *Handler) MarkSeasonAsWatched(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	season, err := strconv.Atoi(c.Param("season"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "season must be a number"})
+		return
+	}
+
+	if err := h.Series.MarkSeasonAsWatched(c.Param("id"), userId, season); err != nil {
+		seriesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"movies-backend/library"
+	"movies-backend/utils"
+	"movies-backend/utils/token"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetSyncChanges(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var since uint64
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		if since, err = strconv.ParseUint(sinceStr, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a cursor returned by a previous sync"})
+			return
+		}
+	}
+
+	changes, err := h.Movies.GetChanges(userId, since)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+type SyncInput struct {
+	Mutations []library.SyncMutation `json:"mutations" binding:"required,dive"`
+}
+
+func (h *Handler) PostSyncMutations(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input SyncInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := library.ApplySyncMutations(h.Movies, userId, input.Mutations)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, result := range response.Results {
+		if result.Status == library.SyncApplied {
+			go utils.TriggerModelRetrain()
+			utils.ClearUserMovieSuggestionCache(userId)
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
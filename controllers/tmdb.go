@@ -1,27 +1,174 @@
 package controllers
 
 import (
+	"errors"
 	"movies-backend/models"
+	"movies-backend/providers"
+	"movies-backend/utils/autocomplete"
 	"movies-backend/utils/token"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetPopularMovies(c *gin.Context) {
-	options := map[string]string{
-		"language": "en-US",
-		"page":     "1",
+// Languages and regions the popular movies are served in unless POPULAR_LANGUAGES and
+// POPULAR_REGIONS list others. The endpoint is public so anything else is refused rather than
+// spread over the cache.
+const (
+	defaultPopularLanguages = "en-US,en-GB,de-DE,el-GR,es-ES,fr-FR,it-IT,ja-JP,ko-KR,nl-NL,pt-BR,pt-PT,ru-RU,sv-SE,tr-TR,zh-CN"
+	defaultPopularRegions   = "US,GB,CA,AU,IN,DE,GR,ES,FR,IT,JP,KR,NL,BR,PT,RU,SE,TR,CN,MX"
+)
+
+// canonicalLanguage writes a BCP 47 tag the way TMDb expects it, as in en-US or zh-Hant-TW
+func canonicalLanguage(tag string) string {
+	subtags := strings.Split(tag, "-")
+	for i, subtag := range subtags {
+		switch {
+		case i > 0 && len(subtag) == 2:
+			subtags[i] = strings.ToUpper(subtag)
+		case i > 0 && len(subtag) == 4:
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+// allowed reports whether value is in the comma separated list read from env, or else from fallback
+func allowed(env string, fallback string, value string, canonical func(string) string) bool {
+	list := os.Getenv(env)
+	if list == "" {
+		list = fallback
+	}
+
+	for _, item := range strings.Split(list, ",") {
+		if canonical(strings.TrimSpace(item)) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TMDb serves at most 500 pages of a list. Regions are uppercased before they are checked against
+// the allowed regions so gr and GR are the same region.
+type PopularInput struct {
+	Page     int    `form:"page" binding:"omitempty,min=1,max=500"`
+	Language string `form:"language" binding:"omitempty,bcp47_language_tag"`
+	Region   string `form:"region" binding:"omitempty,len=2,alpha"`
+}
+
+func (h *Handler) GetPopularMovies(c *gin.Context) {
+	var input PopularInput
+
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options := providers.ListOptions{Language: canonicalLanguage(input.Language), Page: input.Page, Region: strings.ToUpper(input.Region)}
+	if options.Language == "" {
+		options.Language = "en-US"
+	}
+	if options.Page == 0 {
+		options.Page = 1
+	}
+
+	if !allowed("POPULAR_LANGUAGES", defaultPopularLanguages, options.Language, canonicalLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "language " + options.Language + " is not supported"})
+		return
 	}
 
-	popularMovies, err := models.TMDbClient.GetMoviePopular(options)
+	if options.Region != "" && !allowed("POPULAR_REGIONS", defaultPopularRegions, options.Region, strings.ToUpper) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "region " + options.Region + " is not supported"})
+		return
+	}
+
+	popularMovies, err := h.Metadata.PopularMovies(options)
+
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "popular movies failed: " + err.Error()})
+		return
+	}
+
+	// Authentication is optional, without a valid token the results carry no library state
+	userId, _ := token.ExtractTokenID(c)
+
+	annotated, err := h.Movies.Annotate(userId, popularMovies)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, popularMovies)
+	c.JSON(http.StatusOK, annotated)
+}
+
+type MovieDetailsInput struct {
+	Language string `form:"language" binding:"omitempty,bcp47_language_tag"`
+}
+
+// GetMovieDetails returns everything known about a movie, localized to the language asked for
+// or else to the language of the user
+func (h *Handler) GetMovieDetails(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tmdbID, err := strconv.ParseUint(c.Param("tmdbId"), 10, 32)
+
+	if err != nil || tmdbID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tmdbId must be a TMDb movie ID"})
+		return
+	}
+
+	var input MovieDetailsInput
+
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	language := input.Language
+	if language == "" {
+		prefs, err := h.Users.GetPreferences(userId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		language = prefs.Language
+	}
+
+	details, err := h.Metadata.MovieDetails(providers.MovieRef{TMDbID: uint(tmdbID)}, providers.DetailsOptions{Language: language})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, providers.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "movie details failed: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+// GetMetadataCacheStats reports the hits and misses of the metadata cache per operation
+func GetMetadataCacheStats(c *gin.Context) {
+	if models.MetadataCache == nil {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MetadataCache.Stats())
 }
 
 func UpdateReleaseDates(c *gin.Context) {
@@ -32,23 +179,33 @@ func UpdateReleaseDates(c *gin.Context) {
 		return
 	}
 
-	// Find all movies without release release_date
-	movies := models.GetMoviesWithoutReleaseDateByUserID(userId)
+	// Fetch again the releases of every movie that is not available yet, however recent they are
+	now := time.Now().UTC()
+	films, err := models.GetFilmsNeedingReleasesByUserID(userId, now, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	for _, movie := range movies {
-		movie.UpdateReleaseDate()
-		_ = movie.UpdateMovie()
+	for _, film := range films {
+		film.UpdateReleaseDate()
+		_ = film.UpdateFilm()
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
 
 type SearchInput struct {
-	Term string `form:"term" binding:"required"`
+	Term               string `form:"term" binding:"required"`
+	Page               int    `form:"page" binding:"omitempty,min=1,max=500"`
+	Year               int    `form:"year" binding:"omitempty,min=1870,max=2100"`
+	PrimaryReleaseYear int    `form:"primary_release_year" binding:"omitempty,min=1870,max=2100"`
+	Language           string `form:"language" binding:"omitempty,bcp47_language_tag"`
+	Region             string `form:"region" binding:"omitempty,iso3166_1_alpha2"`
 }
 
-func SearchForMovie(c *gin.Context) {
-	_, err := token.ExtractTokenID(c)
+func (h *Handler) SearchForMovie(c *gin.Context) {
+	userId, err := token.ExtractTokenID(c)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -62,18 +219,44 @@ func SearchForMovie(c *gin.Context) {
 		return
 	}
 
-	options := map[string]string{
-		"language":      "en-US",
-		"page":          "1",
-		"include_adult": "false",
+	options := providers.SearchOptions{
+		Language:           input.Language,
+		Page:               input.Page,
+		Year:               input.Year,
+		PrimaryReleaseYear: input.PrimaryReleaseYear,
+		Region:             input.Region,
+	}
+	if options.Language == "" {
+		options.Language = "en-US"
+	}
+	if options.Page == 0 {
+		options.Page = 1
+	}
+
+	movies, err := h.Metadata.SearchMovies(input.Term, options)
+
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "movie search failed: " + err.Error()})
+		return
+	}
+
+	annotated, err := h.Movies.Annotate(userId, movies)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	movies, _ := models.TMDbClient.GetSearchMovies(input.Term, options)
+	c.JSON(http.StatusOK, annotated)
+}
 
-	c.JSON(http.StatusOK, movies)
+type AutocompleteInput struct {
+	Term     string `form:"term" binding:"required"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=20"`
+	Language string `form:"language" binding:"omitempty,bcp47_language_tag"`
 }
 
-func AutocompleteSearch(c *gin.Context) {
+func (h *Handler) AutocompleteSearch(c *gin.Context) {
 	_, err := token.ExtractTokenID(c)
 
 	if err != nil {
@@ -81,31 +264,26 @@ func AutocompleteSearch(c *gin.Context) {
 		return
 	}
 
-	var input SearchInput
+	var input AutocompleteInput
 
 	if err := c.ShouldBind(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	options := map[string]string{
-		"language":      "en-US",
-		"page":          "1",
-		"include_adult": "false",
+	if input.Limit == 0 {
+		input.Limit = 10
+	}
+	if input.Language == "" {
+		input.Language = "en-US"
 	}
 
-	movies, _ := models.TMDbClient.GetSearchMovies(input.Term, options)
-
-	var autocompleteResults [][3]string
-	const imageURL = "https://image.tmdb.org/t/p/w92"
+	suggestions, err := autocomplete.Suggest(h.Metadata, input.Term, input.Limit, input.Language)
 
-	for _, movie := range movies.Results {
-		if len(movie.PosterPath) > 0 {
-			autocompleteResults = append(autocompleteResults, [3]string{movie.OriginalTitle, movie.ReleaseDate, imageURL + movie.PosterPath})
-		} else {
-			autocompleteResults = append(autocompleteResults, [3]string{movie.OriginalTitle, movie.ReleaseDate, movie.PosterPath})
-		}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, autocompleteResults)
+	c.JSON(http.StatusOK, suggestions)
 }
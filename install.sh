@@ -30,11 +30,13 @@ if [ -f .env ] && [ -f movies ] && [ -f movies.service ]; then
         systemctl stop movies.service
         cp movies $INSTALL_PATH
         cp .env $INSTALL_PATH
+        (cd $INSTALL_PATH && ./movies migrate up) || exit 1
         systemctl start movies.service
     else
         mkdir -p $INSTALL_PATH
         cp movies $INSTALL_PATH
         cp .env $INSTALL_PATH
+        (cd $INSTALL_PATH && ./movies migrate up) || exit 1
         cp movies.service /usr/lib/systemd/system
         systemctl start movies.service
         systemctl enable movies.service
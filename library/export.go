@@ -0,0 +1,218 @@
+package library
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"movies-backend/models"
+	"movies-backend/repository"
+	"net/http"
+	"strconv"
+)
+
+// Number of movies read from the database between two flushes of the response
+const exportBatchSize = 200
+
+var ErrUnknownExportFormat = errors.New("unknown export format, expected csv, json or letterboxd")
+
+// ExportedMovie is one library entry as written by the csv and json exports
+type ExportedMovie struct {
+	ID          uint   `json:"id"`
+	TMDbID      uint   `json:"tmdb_id"`
+	IMDbID      string `json:"imdb_id"`
+	Title       string `json:"title"`
+	Year        int    `json:"year,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	Watchlist   bool   `json:"watchlist"`
+	Downloaded  bool   `json:"downloaded"`
+	Watched     bool   `json:"watched"`
+	Rating      uint   `json:"rating"`
+	AddedAt     string `json:"added_at,omitempty"`
+	WatchedAt   string `json:"watched_at,omitempty"`
+}
+
+func toExportedMovie(movie models.Movie) ExportedMovie {
+	exported := ExportedMovie{
+		ID:         movie.ID,
+		TMDbID:     movie.MovieID,
+		Title:      movie.Title,
+		Watchlist:  !movie.Downloaded,
+		Downloaded: movie.Downloaded,
+		Watched:    movie.Watched,
+		Rating:     movie.Rating,
+	}
+
+	if movie.ReleaseDate != nil {
+		exported.ReleaseDate = movie.ReleaseDate.String()
+	}
+
+	if movie.Metadata != nil {
+		exported.IMDbID = movie.Metadata.IMDbID
+		exported.Year = movie.Metadata.ReleaseYear
+	}
+
+	if !movie.CreatedAt.IsZero() {
+		exported.AddedAt = movie.CreatedAt.Format("2006-01-02")
+	}
+
+	if movie.WatchedAt != nil {
+		exported.WatchedAt = movie.WatchedAt.Format("2006-01-02")
+	}
+
+	return exported
+}
+
+func flush(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func formatYear(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+func formatRating(rating uint) string {
+	if rating == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(rating))
+}
+
+func exportCSV(w io.Writer, movies repository.MovieRepository, uid uint) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"id", "tmdb_id", "imdb_id", "title", "year", "release_date", "watchlist", "downloaded", "watched", "rating", "added_at", "watched_at"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	return movies.ForEachMovie(uid, exportBatchSize, func(batch []models.Movie) error {
+		for _, movie := range batch {
+			exported := toExportedMovie(movie)
+			record := []string{
+				strconv.Itoa(int(exported.ID)),
+				strconv.Itoa(int(exported.TMDbID)),
+				exported.IMDbID,
+				exported.Title,
+				formatYear(exported.Year),
+				exported.ReleaseDate,
+				strconv.FormatBool(exported.Watchlist),
+				strconv.FormatBool(exported.Downloaded),
+				strconv.FormatBool(exported.Watched),
+				strconv.Itoa(int(exported.Rating)),
+				exported.AddedAt,
+				exported.WatchedAt,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		flush(w)
+		return writer.Error()
+	})
+}
+
+func exportJSON(w io.Writer, movies repository.MovieRepository, uid uint) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := movies.ForEachMovie(uid, exportBatchSize, func(batch []models.Movie) error {
+		for _, movie := range batch {
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+
+			encoded, err := json.Marshal(toExportedMovie(movie))
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(encoded); err != nil {
+				return err
+			}
+		}
+
+		flush(w)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// exportLetterboxd writes the columns of the Letterboxd import format. Letterboxd imports a
+// file either as watched films or as a watchlist so only one of the two lists is exported.
+func exportLetterboxd(w io.Writer, movies repository.MovieRepository, uid uint, watchlist bool) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"tmdbID", "imdbID", "Title", "Year", "Rating", "WatchedDate"}); err != nil {
+		return err
+	}
+
+	return movies.ForEachMovie(uid, exportBatchSize, func(batch []models.Movie) error {
+		for _, movie := range batch {
+			include := movie.Watched || movie.Rating > 0
+			if watchlist {
+				include = !movie.Downloaded
+			}
+			if !include {
+				continue
+			}
+
+			exported := toExportedMovie(movie)
+			record := []string{
+				strconv.Itoa(int(exported.TMDbID)),
+				exported.IMDbID,
+				exported.Title,
+				formatYear(exported.Year),
+				formatRating(exported.Rating),
+				exported.WatchedAt,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		flush(w)
+		return writer.Error()
+	})
+}
+
+// ExportContentType returns the content type and file extension of an export format
+func ExportContentType(format string) (string, string, error) {
+	switch format {
+	case "csv", "letterboxd":
+		return "text/csv; charset=utf-8", "csv", nil
+	case "json":
+		return "application/json; charset=utf-8", "json", nil
+	}
+	return "", "", ErrUnknownExportFormat
+}
+
+// Export streams the whole user library to w in the requested format
+func Export(w io.Writer, movies repository.MovieRepository, uid uint, format string, watchlist bool) error {
+	switch format {
+	case "csv":
+		return exportCSV(w, movies, uid)
+	case "json":
+		return exportJSON(w, movies, uid)
+	case "letterboxd":
+		return exportLetterboxd(w, movies, uid, watchlist)
+	}
+	return ErrUnknownExportFormat
+}
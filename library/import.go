@@ -0,0 +1,303 @@
+package library
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"movies-backend/models"
+	"movies-backend/providers"
+	"movies-backend/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ratings in this app use a five star scale
+const maxRating = 5
+
+// Maximum number of candidates reported for an ambiguous row
+const maxCandidates = 5
+
+var ErrUnknownImportFormat = errors.New("unknown import file, expected a Letterboxd or IMDb CSV export")
+
+// importFormat describes what the rows of an export mean, rows that are not watched go to the watchlist
+type importFormat struct {
+	watched bool
+	// rating converts the rating column of the export to this app's scale
+	rating func(string) uint
+}
+
+var (
+	letterboxdWatched   = importFormat{watched: true}
+	letterboxdWatchlist = importFormat{}
+	letterboxdRatings   = importFormat{watched: true, rating: letterboxdRating}
+	imdbRatings         = importFormat{watched: true, rating: imdbRating}
+	imdbWatchlist       = importFormat{}
+)
+
+type ImportCandidate struct {
+	TMDbID      int64  `json:"tmdb_id"`
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+}
+
+type ImportProblem struct {
+	File       string            `json:"file"`
+	Line       int               `json:"line"`
+	Title      string            `json:"title"`
+	Year       string            `json:"year,omitempty"`
+	IMDbID     string            `json:"imdb_id,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Candidates []ImportCandidate `json:"candidates,omitempty"`
+}
+
+type ImportReport struct {
+	Created   int             `json:"created"`
+	Restored  int             `json:"restored"`
+	Updated   int             `json:"updated"`
+	Unchanged int             `json:"unchanged"`
+	Unmatched []ImportProblem `json:"unmatched"`
+	Ambiguous []ImportProblem `json:"ambiguous"`
+	Failed    []ImportProblem `json:"failed"`
+}
+
+func NewImportReport() *ImportReport {
+	return &ImportReport{Unmatched: []ImportProblem{}, Ambiguous: []ImportProblem{}, Failed: []ImportProblem{}}
+}
+
+// importRow holds the columns shared by every supported export
+type importRow struct {
+	line      int
+	title     string
+	year      string
+	imdbID    string
+	date      string
+	rating    string
+	watchedAt *time.Time
+}
+
+// detectFormat recognises the export from its header. Letterboxd uses the same columns
+// for watched and watchlist files so the file name tells them apart.
+func detectFormat(filename string, columns map[string]int) (importFormat, error) {
+	_, hasLetterboxdURI := columns["letterboxd uri"]
+	_, hasConst := columns["const"]
+	_, hasRating := columns["rating"]
+	_, hasYourRating := columns["your rating"]
+
+	switch {
+	case hasLetterboxdURI && hasRating:
+		return letterboxdRatings, nil
+	case hasLetterboxdURI && strings.Contains(strings.ToLower(filename), "watchlist"):
+		return letterboxdWatchlist, nil
+	case hasLetterboxdURI:
+		return letterboxdWatched, nil
+	case hasConst && hasYourRating:
+		return imdbRatings, nil
+	case hasConst:
+		return imdbWatchlist, nil
+	}
+
+	return importFormat{}, ErrUnknownImportFormat
+}
+
+func letterboxdRating(value string) uint {
+	rating, err := strconv.ParseFloat(value, 64)
+	if err != nil || rating <= 0 {
+		return 0
+	}
+	// Letterboxd rates from half a star to five stars
+	return uint(math.Min(math.Ceil(rating), maxRating))
+}
+
+func imdbRating(value string) uint {
+	rating, err := strconv.Atoi(value)
+	if err != nil || rating <= 0 {
+		return 0
+	}
+	// IMDb rates from 1 to 10
+	return uint(math.Min(math.Ceil(float64(rating)/2), maxRating))
+}
+
+func column(record []string, columns map[string]int, names ...string) string {
+	for _, name := range names {
+		if i, found := columns[name]; found && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+	}
+	return ""
+}
+
+// ImportCSV matches the rows of a Letterboxd or IMDb export to TMDb movies and adds them to the user library
+func ImportCSV(movies repository.MovieRepository, metadata providers.Provider, uid uint, filename string, file io.Reader, report *ImportReport) error {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", filename, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	format, err := detectFormat(filename, columns)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			report.Failed = append(report.Failed, ImportProblem{File: filename, Line: line, Error: err.Error()})
+			continue
+		}
+
+		row := importRow{
+			line:   line,
+			title:  column(record, columns, "name", "title"),
+			year:   column(record, columns, "year"),
+			imdbID: column(record, columns, "const"),
+			date:   column(record, columns, "date", "date rated", "created"),
+			rating: column(record, columns, "rating", "your rating"),
+		}
+
+		if row.title == "" && row.imdbID == "" {
+			continue
+		}
+
+		importRowToLibrary(movies, metadata, uid, filename, format, row, report)
+	}
+
+	return nil
+}
+
+func importRowToLibrary(movies repository.MovieRepository, metadata providers.Provider, uid uint, filename string, format importFormat, row importRow, report *ImportReport) {
+	problem := ImportProblem{File: filename, Line: row.line, Title: row.title, Year: row.year, IMDbID: row.imdbID}
+
+	match, candidates, err := matchMovie(metadata, row)
+	if err != nil {
+		problem.Error = err.Error()
+		report.Failed = append(report.Failed, problem)
+		return
+	}
+
+	if match == nil {
+		if len(candidates) > 0 {
+			problem.Candidates = candidates
+			report.Ambiguous = append(report.Ambiguous, problem)
+		} else {
+			report.Unmatched = append(report.Unmatched, problem)
+		}
+		return
+	}
+
+	imported := models.ImportedMovie{
+		TMDbID:  uint(match.id),
+		Title:   match.title,
+		Image:   match.posterPath,
+		Watched: format.watched,
+	}
+
+	if format.rating != nil {
+		imported.Rating = format.rating(row.rating)
+	}
+
+	if format.watched && row.date != "" {
+		if watchedAt, err := time.Parse("2006-01-02", row.date); err == nil {
+			imported.WatchedAt = &watchedAt
+		}
+	}
+
+	outcome, err := movies.Import(uid, imported)
+	if err != nil {
+		problem.Error = err.Error()
+		report.Failed = append(report.Failed, problem)
+		return
+	}
+
+	switch outcome {
+	case models.ImportCreated:
+		report.Created++
+	case models.ImportRestored:
+		report.Restored++
+	case models.ImportUpdated:
+		report.Updated++
+	default:
+		report.Unchanged++
+	}
+}
+
+type matchedMovie struct {
+	id         int64
+	title      string
+	posterPath string
+}
+
+// matchMovie looks the row up by IMDb ID when the export has one, otherwise by title and year.
+// It returns either the matched movie or the candidates when the row is ambiguous.
+func matchMovie(metadata providers.Provider, row importRow) (*matchedMovie, []ImportCandidate, error) {
+	if row.imdbID != "" {
+		found, err := metadata.MovieDetails(providers.MovieRef{IMDbID: row.imdbID}, providers.DetailsOptions{Language: "en-US"})
+		if errors.Is(err, providers.ErrNotFound) || (err == nil && found.ID == 0) {
+			// IMDb exports also list series and episodes which have no TMDb movie
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return &matchedMovie{id: int64(found.ID), title: found.Title, posterPath: found.PosterPath}, nil, nil
+	}
+
+	options := providers.SearchOptions{Language: "en-US", Page: 1}
+	if year, err := strconv.Atoi(row.year); err == nil {
+		options.Year = year
+	}
+
+	results, err := metadata.SearchMovies(row.title, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(results.Results) == 0 {
+		return nil, nil, nil
+	}
+
+	var exact []matchedMovie
+	var candidates []ImportCandidate
+	for _, result := range results.Results {
+		if result.ID == 0 {
+			// Only providers knowing TMDb IDs can match a row
+			continue
+		}
+		movie := matchedMovie{id: int64(result.ID), title: result.Title, posterPath: result.PosterPath}
+
+		sameTitle := strings.EqualFold(result.Title, row.title) || strings.EqualFold(result.OriginalTitle, row.title)
+		sameYear := row.year == "" || strings.HasPrefix(result.ReleaseDate, row.year)
+		if sameTitle && sameYear {
+			exact = append(exact, movie)
+		}
+
+		if len(candidates) < maxCandidates {
+			candidates = append(candidates, ImportCandidate{TMDbID: int64(result.ID), Title: result.Title, ReleaseDate: result.ReleaseDate})
+		}
+	}
+
+	switch {
+	case len(exact) == 1:
+		return &exact[0], nil, nil
+	case len(exact) == 0 && len(results.Results) == 1 && results.Results[0].ID != 0:
+		return &matchedMovie{id: int64(results.Results[0].ID), title: results.Results[0].Title, posterPath: results.Results[0].PosterPath}, nil, nil
+	}
+
+	return nil, candidates, nil
+}
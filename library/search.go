@@ -0,0 +1,220 @@
+package library
+
+import (
+	"movies-backend/models"
+	"movies-backend/repository"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Number of movies read from the database at a time while searching
+const searchBatchSize = 500
+
+// How much a match in each field counts towards the score of a movie
+const (
+	titleWeight         = 1.0
+	originalTitleWeight = 0.9
+	castWeight          = 0.6
+	genreWeight         = 0.5
+	overviewWeight      = 0.3
+)
+
+type SearchHit struct {
+	models.Movie
+	Score float64 `json:"score"`
+}
+
+type searchField struct {
+	weight float64
+	tokens []string
+}
+
+// normalize lowercases the text, strips accents and turns punctuation into spaces
+func normalize(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop the accents split off by NFD
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func tokenize(text string) []string {
+	return strings.Fields(normalize(text))
+}
+
+// maxTypos is how many edits a query token of the given length may be away from a word
+func maxTypos(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the optimal string alignment distance, it gives up once the distance exceeds limit
+func editDistance(a, b []rune, limit int) int {
+	if abs(len(a)-len(b)) > limit {
+		return limit + 1
+	}
+
+	previous2 := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+
+	return previous[len(b)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// matchToken scores how well a query token matches the best word of a field
+func matchToken(query string, words []string) float64 {
+	best := 0.0
+	queryRunes := []rune(query)
+	limit := maxTypos(len(queryRunes))
+
+	for _, word := range words {
+		score := 0.0
+		switch {
+		case word == query:
+			score = 1
+		case len(queryRunes) > 1 && strings.HasPrefix(word, query):
+			score = 0.8
+		default:
+			if distance := editDistance(queryRunes, []rune(word), limit); distance <= limit {
+				score = 0.7 - 0.1*float64(distance)
+			}
+		}
+		best = max(best, score)
+	}
+
+	return best
+}
+
+func searchFields(movie models.Movie) []searchField {
+	fields := []searchField{{titleWeight, tokenize(movie.Title)}}
+
+	if metadata := movie.Metadata; metadata != nil {
+		fields = append(fields, searchField{originalTitleWeight, tokenize(metadata.OriginalTitle)})
+
+		var cast []string
+		for _, member := range metadata.Cast {
+			cast = append(cast, tokenize(member.Name)...)
+		}
+		fields = append(fields, searchField{castWeight, cast})
+
+		var genres []string
+		for _, genre := range metadata.Genres {
+			genres = append(genres, tokenize(genre.Name)...)
+		}
+		fields = append(fields, searchField{genreWeight, genres})
+
+		fields = append(fields, searchField{overviewWeight, tokenize(metadata.Overview)})
+	}
+
+	return fields
+}
+
+// scoreMovie returns 0 unless every query token matches some field
+func scoreMovie(movie models.Movie, query string, queryTokens []string) float64 {
+	fields := searchFields(movie)
+	total := 0.0
+
+	for _, token := range queryTokens {
+		best := 0.0
+		for _, field := range fields {
+			best = max(best, field.weight*matchToken(token, field.tokens))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	score := total / float64(len(queryTokens))
+
+	// Reward titles matching the whole query
+	title := normalize(movie.Title)
+	switch {
+	case title == query:
+		score += 1
+	case strings.HasPrefix(title, query):
+		score += 0.5
+	case strings.Contains(title, query):
+		score += 0.25
+	}
+
+	return score
+}
+
+// Search ranks the movies of the user library, trash excluded, against the query
+func Search(movies repository.MovieRepository, uid uint, query string, limit int) ([]SearchHit, error) {
+	hits := []SearchHit{}
+
+	query = normalize(query)
+	queryTokens := strings.Fields(query)
+	if len(queryTokens) == 0 {
+		return hits, nil
+	}
+
+	err := movies.ForEachMovie(uid, searchBatchSize, func(batch []models.Movie) error {
+		for _, movie := range batch {
+			if score := scoreMovie(movie, query, queryTokens); score > 0 {
+				hits = append(hits, SearchHit{Movie: movie, Score: score})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return hits, err
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,187 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"movies-backend/models"
+	"movies-backend/repository"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Operations a sync client can queue while offline
+const (
+	SyncAdd        = "add"
+	SyncDelete     = "delete"
+	SyncRestore    = "restore"
+	SyncDownloaded = "downloaded"
+	SyncWatched    = "watched"
+	SyncRate       = "rate"
+	SyncMove       = "move"
+)
+
+// Outcome of a queued mutation
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncFailed   = "failed"
+)
+
+var ErrUnknownSyncOperation = errors.New("unknown sync operation")
+
+// SyncMutation is a change made by a client while offline. BaseSeq is the change_seq of the
+// entry when the client last synced it.
+type SyncMutation struct {
+	ClientID string `json:"client_id"`
+	Op       string `json:"op" binding:"required"`
+	ID       uint   `json:"id"`
+	MovieID  uint   `json:"movie_id"`
+	Title    string `json:"title"`
+	Image    string `json:"image"`
+	Rating   uint   `json:"rating"`
+	AfterID  uint   `json:"after_id"`
+	BaseSeq  uint64 `json:"base_seq"`
+}
+
+// SyncResult reports the outcome of a mutation along with the server copy of the entry
+type SyncResult struct {
+	ClientID string        `json:"client_id"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Movie    *models.Movie `json:"movie,omitempty"`
+}
+
+type SyncResponse struct {
+	Cursor  uint64       `json:"cursor"`
+	Results []SyncResult `json:"results"`
+}
+
+// conditional tells whether the operation conflicts with a server change made after the client
+// last synced the entry. Marking as downloaded or watched only ever sets a flag so the result is
+// the same in any order and those operations never conflict.
+func conditional(op string) bool {
+	switch op {
+	case SyncDelete, SyncRestore, SyncRate, SyncMove:
+		return true
+	}
+	return false
+}
+
+// ApplySyncMutations applies the queued mutations in order. The conflict policy is:
+//
+//   - add never conflicts, adding a movie already in the library returns the existing entry
+//   - downloaded and watched never conflict, they are applied or are already in effect
+//   - delete, restore, rate and move conflict when the entry changed on the server after
+//     BaseSeq. The server copy wins, the mutation is not applied and the result carries the
+//     server copy so the client can decide again and resend it with the new change_seq.
+//   - a write losing the race against a concurrent request is a conflict whatever the operation
+//
+// A failed or conflicting mutation does not stop the ones after it.
+func ApplySyncMutations(movies repository.MovieRepository, uid uint, mutations []SyncMutation) (SyncResponse, error) {
+	response := SyncResponse{Results: []SyncResult{}}
+
+	for _, mutation := range mutations {
+		response.Results = append(response.Results, applySyncMutation(movies, uid, mutation))
+	}
+
+	version, err := movies.LibraryVersion(uid)
+	if err != nil {
+		return response, err
+	}
+	response.Cursor = version.Version
+
+	return response, nil
+}
+
+func syncFailed(result SyncResult, err error) SyncResult {
+	result.Status = SyncFailed
+	result.Error = err.Error()
+	return result
+}
+
+func applySyncMutation(movies repository.MovieRepository, uid uint, mutation SyncMutation) SyncResult {
+	result := SyncResult{ClientID: mutation.ClientID}
+
+	if mutation.Op == SyncAdd {
+		existing, err := movies.GetEntryByMovieID(uid, mutation.MovieID)
+		if err == nil {
+			result.Status = SyncApplied
+			result.Movie = &existing
+			return result
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return syncFailed(result, err)
+		}
+
+		movie := models.Movie{UserID: uid, MovieID: mutation.MovieID, Title: mutation.Title, Image: mutation.Image}
+		saved, err := movies.AddToWatchlist(movie)
+		if err != nil {
+			return syncFailed(result, err)
+		}
+
+		result.Status = SyncApplied
+		result.Movie = saved
+		return result
+	}
+
+	current, err := movies.GetEntry(mutation.ID, uid)
+	if err != nil {
+		return syncFailed(result, err)
+	}
+
+	if conditional(mutation.Op) && current.ChangeSeq > mutation.BaseSeq {
+		result.Status = SyncConflict
+		result.Movie = &current
+		return result
+	}
+
+	id := fmt.Sprint(mutation.ID)
+
+	// Conditional writes are made on the version checked above so a change in between conflicts
+	switch mutation.Op {
+	case SyncDelete:
+		if current.DeletedAt == nil {
+			err = movies.DeleteFromWatchlist(id, uid, current.Version)
+		}
+	case SyncRestore:
+		if current.DeletedAt != nil {
+			_, err = movies.RestoreFromTrash(id, uid, current.Version)
+		}
+	case SyncDownloaded:
+		if !current.Downloaded {
+			err = movies.MarkAsDownloaded(id, uid, 0)
+		}
+	case SyncWatched:
+		if !current.Watched {
+			err = movies.MarkAsWatched(id, uid, 0)
+		}
+	case SyncRate:
+		err = movies.Rate(id, uid, mutation.Rating, current.Version)
+	case SyncMove:
+		err = movies.ReorderWatchlist(uid, []models.WatchlistMove{{ID: mutation.ID, AfterID: mutation.AfterID, Version: current.Version}})
+	default:
+		err = ErrUnknownSyncOperation
+	}
+
+	if errors.Is(err, models.ErrStaleMovie) {
+		// Changed on the server between the check above and the write
+		if current, err = movies.GetEntry(mutation.ID, uid); err != nil {
+			return syncFailed(result, err)
+		}
+		result.Status = SyncConflict
+		result.Movie = &current
+		return result
+	}
+	if err != nil {
+		return syncFailed(result, err)
+	}
+
+	updated, err := movies.GetEntry(mutation.ID, uid)
+	if err != nil {
+		return syncFailed(result, err)
+	}
+
+	result.Status = SyncApplied
+	result.Movie = &updated
+	return result
+}
@@ -4,56 +4,55 @@ import (
 	"fmt"
 	"log"
 	"movies-backend/controllers"
-	"movies-backend/middlewares"
+	"movies-backend/migrations"
 	"movies-backend/models"
+	"movies-backend/repository"
+	"movies-backend/router"
 	"movies-backend/utils"
-	"net/http"
 	"os"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
 )
 
 func main() {
 
-	models.ConnectDataBase()
-
-	r := gin.Default()
-	r.Use(middlewares.CORSMiddleware())
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		models.OpenDataBase()
+		if err := migrations.Command(models.DB, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("Migration error: ", err)
+		}
+		return
+	}
 
-	public := r.Group("/api")
+	models.ConnectDataBase()
 
-	public.POST("/login", controllers.Login)
+	r := router.New(controllers.NewHandler(repository.NewGormUsers(), repository.NewGormMovies(), repository.NewGormSeries(), models.Metadata))
 
-	public.GET("/popular", controllers.GetPopularMovies)
+	// Schedule movies release date updates every day
+	s := gocron.NewScheduler(time.UTC)
+	if _, err := s.Every(60).Seconds().Do(func() { utils.CheckForAvailableMovies() }); err != nil {
+		log.Fatalf("Error starting cron job")
+	}
 
-	public.GET("/version", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"version": "1.0"})
-	})
+	// Permanently delete movies that stayed in the trash longer than the retention period
+	if _, err := s.Every(1).Day().Do(func() { utils.PurgeExpiredTrash() }); err != nil {
+		log.Fatalf("Error starting trash purge job")
+	}
 
-	private := r.Group("/api")
+	// Drop the persisted metadata responses that expired
+	if _, err := s.Every(1).Day().Do(func() { utils.PurgeExpiredProviderResponses() }); err != nil {
+		log.Fatalf("Error starting metadata cache purge job")
+	}
 
-	private.Use(middlewares.JwtAuthMiddleware())
-	{
-		private.GET("/user", controllers.CurrentUser)
-		private.GET("/watchlist", controllers.GetWatchlist)
-		private.GET("/movies", controllers.GetMovies)
-		private.POST("/watchlist", controllers.AddToWatchlist)
-		private.POST("/movies/mark/downloaded/:id", controllers.MarkMovieAsDownloaded)
-		private.POST("/movies/mark/watched/:id", controllers.MarkMovieAsWatched)
-		private.GET("/movies/suggestion", controllers.MoviesSuggestion)
-		private.POST("/movies/rate/:id", controllers.RateMovie)
-		private.DELETE("/watchlist/:id", controllers.DeleteFromWatchlist)
-		private.GET("/update", controllers.UpdateReleaseDates)
-		private.POST("/search", controllers.SearchForMovie)
-		private.POST("/autocomplete", controllers.AutocompleteSearch)
+	// Fill in missing movie metadata and refresh the outdated one
+	if _, err := s.Every(1).Hour().Do(func() { utils.RefreshStaleMetadata() }); err != nil {
+		log.Fatalf("Error starting metadata refresh job")
 	}
 
-	// Schedule movies release date updates every day
-	s := gocron.NewScheduler(time.UTC)
-	if _, err := s.Every(60).Seconds().Do(func() { utils.CheckForAvailableMovies() }); err != nil {
-		log.Fatalf("Error starting cron job")
+	// Refresh tracked series and notify about newly aired episodes
+	if _, err := s.Every(1).Hour().Do(func() { utils.CheckForNewEpisodes() }); err != nil {
+		log.Fatalf("Error starting series refresh job")
 	}
 	s.StartAsync()
 
@@ -2,7 +2,10 @@ package middlewares
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
+	"movies-backend/models"
 	"movies-backend/utils/token"
 
 	"github.com/gin-gonic/gin"
@@ -23,7 +26,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match, If-None-Match, If-Modified-Since")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -34,3 +38,96 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// etagMatches reports whether the header lists the tag. Weak comparison ignores the W/ prefix.
+func etagMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// libraryVersionWriter sets the ETag of the new library version when a mutation succeeds
+type libraryVersionWriter struct {
+	gin.ResponseWriter
+	versions LibraryVersions
+	userId   uint
+}
+
+func (w *libraryVersionWriter) WriteHeader(code int) {
+	if code < http.StatusMultipleChoices {
+		if version, err := w.versions.LibraryVersion(w.userId); err == nil {
+			w.Header().Set("ETag", version.ETag())
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// LibraryVersions returns the current version of a user library
+type LibraryVersions interface {
+	LibraryVersion(uid uint) (models.LibraryVersion, error)
+}
+
+// LibraryConditionalMiddleware answers conditional requests on the user library. Reads get
+// an ETag and Last-Modified and a 304 when the client copy is current. Writes with an If-Match
+// that does not match the current library version are refused with a 412.
+func LibraryConditionalMiddleware(versions LibraryVersions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := token.ExtractTokenID(c)
+		if err != nil {
+			// Let the handler report the invalid token
+			c.Next()
+			return
+		}
+
+		version, err := versions.LibraryVersion(userId)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		etag := version.ETag()
+
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Header("ETag", etag)
+			c.Header("Cache-Control", "private, no-cache")
+			if !version.UpdatedAt.IsZero() {
+				c.Header("Last-Modified", version.UpdatedAt.UTC().Format(http.TimeFormat))
+			}
+
+			if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+				if etagMatches(ifNoneMatch, etag, true) {
+					c.AbortWithStatus(http.StatusNotModified)
+					return
+				}
+			} else if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" && !version.UpdatedAt.IsZero() {
+				since, err := http.ParseTime(ifModifiedSince)
+				if err == nil && !version.UpdatedAt.Truncate(time.Second).After(since) {
+					c.AbortWithStatus(http.StatusNotModified)
+					return
+				}
+			}
+
+			c.Next()
+			return
+		}
+
+		if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, etag, false) {
+			c.Header("ETag", etag)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "library was modified by another client"})
+			return
+		}
+
+		c.Writer = &libraryVersionWriter{ResponseWriter: c.Writer, versions: versions, userId: userId}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+)
+
+const usage = "usage: migrate up | down [steps] | status"
+
+// Command runs the migrate command: up applies the pending migrations, down reverts the latest
+// ones (one by default) and status lists every migration
+func Command(db *gorm.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "up":
+		return Up(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New("steps must be a positive number")
+			}
+		}
+		return Down(db, steps)
+	case "status":
+		states, err := Status(db)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%03d %-40s %s\n", state.Version, state.Name, applied)
+		}
+		return nil
+	}
+
+	return errors.New(usage)
+}
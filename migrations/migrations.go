@@ -0,0 +1,162 @@
+// Package migrations versions the database schema. Every migration is written against structs
+// frozen at the time it was added so later changes to the models do not change its result.
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration changes the schema from Version-1 to Version. Down reverts it.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// all lists the migrations in the order they are applied
+var all = []Migration{
+	v001Initial,
+	v002MovieVersion,
+	v003ProviderResponses,
+	v004UserLanguage,
+	v005MetadataFailures,
+	v006ReleasesRefreshedAt,
+	v007NotifiedEpisodes,
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+	Version   uint   `gorm:"primary_key;auto_increment:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// State of a migration in the database
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+func applied(db *gorm.DB) (map[uint]schemaMigration, error) {
+	records := map[uint]schemaMigration{}
+
+	if !db.HasTable(&schemaMigration{}) {
+		return records, nil
+	}
+
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		records[row.Version] = row
+	}
+
+	return records, nil
+}
+
+// Status returns every known migration with the time it was applied, nil when it is pending
+func Status(db *gorm.DB) ([]State, error) {
+	records, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, len(all))
+	for i, migration := range all {
+		states[i] = State{Migration: migration}
+		if record, found := records[migration.Version]; found {
+			appliedAt := record.AppliedAt
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return states, nil
+}
+
+// Pending returns the migrations not applied yet
+func Pending(db *gorm.DB) ([]Migration, error) {
+	states, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending = append(pending, state.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies every pending migration in order. Each migration is recorded in the transaction it
+// runs in, but MySQL commits schema changes implicitly so a failed migration may be left half
+// applied there.
+func Up(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return err
+	}
+
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		log.Printf("Applied migration %d %s", migration.Version, migration.Name)
+	}
+
+	return nil
+}
+
+// Down reverts the given number of applied migrations starting from the latest
+func Down(db *gorm.DB, steps int) error {
+	states, err := Status(db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(states) - 1; i >= 0 && steps > 0; i-- {
+		migration := states[i].Migration
+		if states[i].AppliedAt == nil {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("revert migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		log.Printf("Reverted migration %d %s", migration.Version, migration.Name)
+		steps--
+	}
+
+	return nil
+}
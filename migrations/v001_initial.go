@@ -0,0 +1,250 @@
+package migrations
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrIrreversible is returned by the migrations that cannot be reverted
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// v001Initial creates the schema the models had when migrations were introduced. Databases
+// created by AutoMigrate before that already have it, the migration then only adds what is
+// missing and converts the data still in an older layout.
+var v001Initial = Migration{
+	Version: 1,
+	Name:    "initial schema",
+	Up: func(tx *gorm.DB) error {
+		for _, table := range v001Tables() {
+			if err := tx.AutoMigrate(table).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := v001ConvertReleaseDates(tx); err != nil {
+			return err
+		}
+
+		return v001ConvertLegacyWatchlist(tx)
+	},
+	// The tables may predate the migration and the legacy watchlist is converted in place, so
+	// reverting would drop the production data instead of restoring it
+	Down: func(tx *gorm.DB) error {
+		return ErrIrreversible
+	},
+}
+
+type v001User struct {
+	ID               uint   `gorm:"primary_key"`
+	Email            string `gorm:"size:255;not null;unique"`
+	Password         string `gorm:"size:255;not null;"`
+	FirstName        string `gorm:"size:255;not null;"`
+	LastName         string `gorm:"size:255;not null;"`
+	LibraryVersion   uint64 `gorm:"not null;default:0"`
+	LibraryUpdatedAt *time.Time
+	PurgedChangeSeq  uint64 `gorm:"not null;default:0"`
+	Regions          string `gorm:"size:255"`
+	ReleaseTypes     string `gorm:"size:64"`
+}
+
+func (v001User) TableName() string {
+	return "users"
+}
+
+type v001Film struct {
+	ID          uint `gorm:"primary_key;auto_increment:false"`
+	Title       string
+	ReleaseDate *time.Time `gorm:"type:date"`
+	Image       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (v001Film) TableName() string {
+	return "movies"
+}
+
+type v001ReleaseDate struct {
+	ID            uint   `gorm:"primary_key"`
+	FilmID        uint   `gorm:"index"`
+	Country       string `gorm:"size:2"`
+	Type          int
+	Date          time.Time `gorm:"type:date"`
+	Certification string
+	Note          string
+}
+
+func (v001ReleaseDate) TableName() string {
+	return "release_dates"
+}
+
+type v001Movie struct {
+	ID         uint `gorm:"primary_key"`
+	UserID     uint `gorm:"index"`
+	MovieID    uint `gorm:"index"`
+	EmailSent  bool
+	Downloaded bool   `gorm:"default:false"`
+	Watched    bool   `gorm:"default:false"`
+	Rating     uint   `gorm:"default:0"`
+	Position   string `gorm:"size:255;index"`
+	WatchedAt  *time.Time
+	ChangeSeq  uint64 `gorm:"not null;default:0;index"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  *time.Time `sql:"index"`
+}
+
+func (v001Movie) TableName() string {
+	return "user_movies"
+}
+
+type v001Activity struct {
+	ID        uint   `gorm:"primary_key"`
+	UserID    uint   `gorm:"index"`
+	Action    string `gorm:"size:32;not null"`
+	EntryID   uint
+	MovieID   uint
+	Title     string
+	Rating    uint
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (v001Activity) TableName() string {
+	return "activities"
+}
+
+type v001MovieMetadata struct {
+	TMDbID           uint   `gorm:"column:tmdb_id;primary_key;auto_increment:false"`
+	IMDbID           string `gorm:"column:imdb_id;size:16"`
+	OriginalTitle    string
+	OriginalLanguage string `gorm:"size:8"`
+	Overview         string `gorm:"type:text"`
+	Runtime          int
+	ReleaseYear      int
+	Certification    string    `gorm:"size:16"`
+	Cast             string    `gorm:"type:text"`
+	RefreshedAt      time.Time `gorm:"index"`
+}
+
+func (v001MovieMetadata) TableName() string {
+	return "movie_metadata"
+}
+
+type v001MovieGenre struct {
+	ID      uint `gorm:"primary_key"`
+	TMDbID  uint `gorm:"column:tmdb_id;index"`
+	GenreID uint
+	Name    string `gorm:"size:64;index"`
+}
+
+func (v001MovieGenre) TableName() string {
+	return "movie_genres"
+}
+
+type v001Series struct {
+	ID               uint `gorm:"primary_key;auto_increment:false"`
+	Name             string
+	OriginalName     string
+	Overview         string `gorm:"type:text"`
+	Image            string
+	FirstAirDate     *time.Time `gorm:"type:date"`
+	Status           string
+	NumberOfSeasons  int
+	NumberOfEpisodes int
+	RefreshedAt      time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (v001Series) TableName() string {
+	return "series"
+}
+
+type v001Episode struct {
+	ID            uint `gorm:"primary_key;auto_increment:false"`
+	SeriesID      uint `gorm:"index"`
+	SeasonNumber  int  `gorm:"index"`
+	EpisodeNumber int
+	Name          string
+	Overview      string     `gorm:"type:text"`
+	AirDate       *time.Time `gorm:"type:date"`
+	Runtime       int
+}
+
+func (v001Episode) TableName() string {
+	return "episodes"
+}
+
+type v001UserSeries struct {
+	ID            uint       `gorm:"primary_key"`
+	UserID        uint       `gorm:"index"`
+	SeriesID      uint       `gorm:"index"`
+	NotifiedUntil *time.Time `gorm:"type:date"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (v001UserSeries) TableName() string {
+	return "user_series"
+}
+
+type v001WatchedEpisode struct {
+	ID        uint `gorm:"primary_key"`
+	UserID    uint `gorm:"unique_index:idx_watched_episode"`
+	EpisodeID uint `gorm:"unique_index:idx_watched_episode"`
+	SeriesID  uint `gorm:"index"`
+	WatchedAt time.Time
+}
+
+func (v001WatchedEpisode) TableName() string {
+	return "watched_episodes"
+}
+
+func v001Tables() []interface{} {
+	return []interface{}{
+		&v001User{},
+		&v001Film{},
+		&v001ReleaseDate{},
+		&v001Movie{},
+		&v001Activity{},
+		&v001MovieMetadata{},
+		&v001MovieGenre{},
+		&v001Series{},
+		&v001Episode{},
+		&v001UserSeries{},
+		&v001WatchedEpisode{},
+	}
+}
+
+// v001ConvertReleaseDates converts the release date of films from the text column used before
+// dates were stored as dates. AutoMigrate never changes the type of an existing column.
+func v001ConvertReleaseDates(tx *gorm.DB) error {
+	var schema string
+	switch tx.Dialect().GetName() {
+	case "mysql":
+		schema = "DATABASE()"
+	case "postgres":
+		schema = "current_schema()"
+	default:
+		// SQLite was supported after dates were stored as dates and has no column types to convert
+		return nil
+	}
+
+	var dataType string
+	row := tx.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = "+schema+" AND table_name = ? AND column_name = ?", "movies", "release_date").Row()
+	if err := row.Scan(&dataType); err != nil || dataType == "date" {
+		return nil
+	}
+
+	if err := tx.Exec("UPDATE movies SET release_date = NULL WHERE release_date = ''").Error; err != nil {
+		return err
+	}
+
+	if tx.Dialect().GetName() == "postgres" {
+		return tx.Exec("ALTER TABLE movies ALTER COLUMN release_date TYPE date USING release_date::date").Error
+	}
+
+	return tx.Model(&v001Film{}).ModifyColumn("release_date", "date").Error
+}
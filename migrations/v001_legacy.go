@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Table that held one row per user and movie before films were shared between libraries
+const legacyWatchlistTable = "watchlist"
+
+// Name the legacy table is renamed to once its rows are copied, kept as a backup
+const legacyWatchlistBackupTable = "watchlist_legacy"
+
+type legacyWatchlistEntry struct {
+	ID          uint
+	UserID      uint
+	Title       string
+	ReleaseDate *string
+	Image       string
+	MovieID     uint
+	EmailSent   bool
+	Downloaded  bool
+	Watched     bool
+	Rating      uint
+	Position    string
+	CreatedAt   *time.Time
+	UpdatedAt   *time.Time
+	DeletedAt   *time.Time
+}
+
+func (legacyWatchlistEntry) TableName() string {
+	return legacyWatchlistTable
+}
+
+// v001ConvertLegacyWatchlist splits the rows of the legacy watchlist table into shared films
+// and user library entries. Entry IDs are kept so activity records still point to them.
+func v001ConvertLegacyWatchlist(tx *gorm.DB) error {
+	if !tx.HasTable(legacyWatchlistTable) {
+		return nil
+	}
+
+	// Columns added after the table was first created may be missing on old databases
+	if err := tx.AutoMigrate(&legacyWatchlistEntry{}).Error; err != nil {
+		return err
+	}
+
+	var entries []legacyWatchlistEntry
+	if err := tx.Unscoped().Order("id").Find(&entries).Error; err != nil {
+		return err
+	}
+
+	films := map[uint]*v001Film{}
+	for _, entry := range entries {
+		film, found := films[entry.MovieID]
+		if !found {
+			film = &v001Film{ID: entry.MovieID, Title: entry.Title, Image: entry.Image}
+			films[entry.MovieID] = film
+		}
+		// Several users may have the movie, prefer whichever copy already has a release date
+		if film.ReleaseDate == nil && entry.ReleaseDate != nil {
+			if releaseDate, err := time.Parse("2006-01-02", *entry.ReleaseDate); err == nil {
+				film.ReleaseDate = &releaseDate
+			}
+		}
+	}
+
+	for _, film := range films {
+		if err := tx.Save(film).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		movie := v001Movie{
+			ID:         entry.ID,
+			UserID:     entry.UserID,
+			MovieID:    entry.MovieID,
+			EmailSent:  entry.EmailSent,
+			Downloaded: entry.Downloaded,
+			Watched:    entry.Watched,
+			Rating:     entry.Rating,
+			Position:   entry.Position,
+			DeletedAt:  entry.DeletedAt,
+		}
+		if entry.CreatedAt != nil {
+			movie.CreatedAt = *entry.CreatedAt
+		}
+		if entry.UpdatedAt != nil {
+			movie.UpdatedAt = *entry.UpdatedAt
+		}
+
+		if err := tx.Create(&movie).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Exec("ALTER TABLE " + legacyWatchlistTable + " RENAME TO " + legacyWatchlistBackupTable).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Migrated %d watchlist rows into %d shared movies", len(entries), len(films))
+	return nil
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import "github.com/jinzhu/gorm"
+
+// v002MovieVersion adds the version library entries are conditionally updated with
+var v002MovieVersion = Migration{
+	Version: 2,
+	Name:    "movie version",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v002Movie{}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Dialect().GetName() == "sqlite3" {
+			// The bundled SQLite cannot drop columns, the unused column stays behind
+			return nil
+		}
+		return tx.Model(&v002Movie{}).DropColumn("version").Error
+	},
+}
+
+type v002Movie struct {
+	Version uint `gorm:"not null;default:1"`
+}
+
+func (v002Movie) TableName() string {
+	return "user_movies"
+}
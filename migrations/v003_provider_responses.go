@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// v003ProviderResponses adds the table the metadata provider cache persists responses in
+var v003ProviderResponses = Migration{
+	Version: 3,
+	Name:    "provider responses",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v003ProviderResponse{}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.DropTableIfExists(&v003ProviderResponse{}).Error
+	},
+}
+
+type v003ProviderResponse struct {
+	CacheKey  string    `gorm:"primary_key;size:64"`
+	Body      string    `gorm:"type:text"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (v003ProviderResponse) TableName() string {
+	return "provider_responses"
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import "github.com/jinzhu/gorm"
+
+// v004UserLanguage adds the language users want movie metadata in
+var v004UserLanguage = Migration{
+	Version: 4,
+	Name:    "user language",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v004User{}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Dialect().GetName() == "sqlite3" {
+			// The bundled SQLite cannot drop columns, the unused column stays behind
+			return nil
+		}
+		return tx.Model(&v004User{}).DropColumn("language").Error
+	},
+}
+
+type v004User struct {
+	Language string `gorm:"size:16"`
+}
+
+func (v004User) TableName() string {
+	return "users"
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// v005MetadataFailures adds the backoff of films whose metadata cannot be fetched
+var v005MetadataFailures = Migration{
+	Version: 5,
+	Name:    "metadata failures",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v005Film{}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Dialect().GetName() == "sqlite3" {
+			// The bundled SQLite cannot drop columns, the unused columns stay behind
+			return nil
+		}
+		if err := tx.Model(&v005Film{}).DropColumn("metadata_failures").Error; err != nil {
+			return err
+		}
+		return tx.Model(&v005Film{}).DropColumn("metadata_retry_at").Error
+	},
+}
+
+type v005Film struct {
+	MetadataFailures int `gorm:"not null;default:0"`
+	MetadataRetryAt  *time.Time
+}
+
+func (v005Film) TableName() string {
+	return "movies"
+}
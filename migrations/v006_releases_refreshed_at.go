@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// v006ReleasesRefreshedAt adds the time the releases of a film were last fetched
+var v006ReleasesRefreshedAt = Migration{
+	Version: 6,
+	Name:    "releases refreshed at",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v006Film{}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Dialect().GetName() == "sqlite3" {
+			// The bundled SQLite cannot drop columns, the unused column stays behind
+			return nil
+		}
+		return tx.Model(&v006Film{}).DropColumn("releases_refreshed_at").Error
+	},
+}
+
+type v006Film struct {
+	ReleasesRefreshedAt *time.Time
+}
+
+func (v006Film) TableName() string {
+	return "movies"
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// v007NotifiedEpisodes adds the episodes users were notified about on the day they were last
+// notified, so episodes listed later with the same air date are still notified
+var v007NotifiedEpisodes = Migration{
+	Version: 7,
+	Name:    "notified episodes",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v007NotifiedEpisode{}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.DropTableIfExists(&v007NotifiedEpisode{}).Error
+	},
+}
+
+type v007NotifiedEpisode struct {
+	ID        uint      `gorm:"primary_key"`
+	UserID    uint      `gorm:"unique_index:idx_notified_episode"`
+	EpisodeID uint      `gorm:"unique_index:idx_notified_episode"`
+	SeriesID  uint      `gorm:"index"`
+	AirDate   time.Time `gorm:"type:date"`
+}
+
+func (v007NotifiedEpisode) TableName() string {
+	return "notified_episodes"
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	ActivityAdded      = "added"
+	ActivityDeleted    = "deleted"
+	ActivityRestored   = "restored"
+	ActivityDownloaded = "downloaded"
+	ActivityWatched    = "watched"
+	ActivityRated      = "rated"
+	ActivityLogin      = "login"
+)
+
+// Activity is an append-only record of something that happened in a user library.
+// Rows are never updated or deleted so the title is copied to outlive the movie.
+type Activity struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Action    string    `gorm:"size:32;not null" json:"action"`
+	EntryID   uint      `json:"entry_id,omitempty"`
+	MovieID   uint      `json:"movie_id,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Rating    uint      `json:"rating,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+type ActivityFilter struct {
+	From   *time.Time
+	To     *time.Time
+	Action string
+	Limit  int
+}
+
+func RecordActivity(uid uint, action string, movie *Movie) {
+	activity := Activity{UserID: uid, Action: action}
+
+	if movie != nil {
+		activity.EntryID = movie.ID
+		activity.MovieID = movie.MovieID
+		activity.Title = movie.Title
+		activity.Rating = movie.Rating
+	}
+
+	if err := DB.Create(&activity).Error; err != nil {
+		log.Println("Error recording activity", action, err)
+	}
+}
+
+func GetActivityByUserID(uid uint, filter ActivityFilter) ([]Activity, error) {
+	var activities []Activity
+
+	query := DB.Where("user_id = ?", uid)
+
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Order("created_at desc, id desc").Find(&activities).Error; err != nil {
+		return activities, fmt.Errorf("activity for user id %d not found", uid)
+	}
+
+	return activities, nil
+}
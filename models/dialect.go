@@ -0,0 +1,37 @@
+package models
+
+import "fmt"
+
+// Names gorm gives to the supported database dialects
+const (
+	dialectMySQL    = "mysql"
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite3"
+)
+
+// yearOf returns the SQL expression extracting the year of a timestamp column
+func yearOf(column string) string {
+	if DB.Dialect().GetName() == dialectSQLite {
+		return fmt.Sprintf("CAST(strftime('%%Y', %s) AS INTEGER)", column)
+	}
+	return fmt.Sprintf("EXTRACT(YEAR FROM %s)", column)
+}
+
+// monthOf returns the SQL expression extracting the month of a timestamp column
+func monthOf(column string) string {
+	if DB.Dialect().GetName() == dialectSQLite {
+		return fmt.Sprintf("CAST(strftime('%%m', %s) AS INTEGER)", column)
+	}
+	return fmt.Sprintf("EXTRACT(MONTH FROM %s)", column)
+}
+
+// secondsBetween returns the SQL expression of the seconds elapsed from one timestamp column to another
+func secondsBetween(from string, to string) string {
+	switch DB.Dialect().GetName() {
+	case dialectPostgres:
+		return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", to, from)
+	case dialectSQLite:
+		return fmt.Sprintf("(julianday(%s) - julianday(%s)) * 86400", to, from)
+	}
+	return fmt.Sprintf("TIMESTAMPDIFF(SECOND, %s, %s)", from, to)
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"movies-backend/providers"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Film is a movie shared by every library that contains it. Its ID is the TMDb ID
+// so it matches Movie.MovieID.
+type Film struct {
+	ID          uint          `gorm:"primary_key;auto_increment:false" json:"id"`
+	Title       string        `json:"title"`
+	ReleaseDate *Date         `gorm:"type:date" json:"release_date"`
+	Image       string        `json:"image"`
+	Releases    []ReleaseDate `gorm:"foreignkey:FilmID;save_associations:false" json:"releases"`
+	// Last time the releases were fetched, or tried to, from the metadata provider
+	ReleasesRefreshedAt *time.Time `json:"-"`
+	// Metadata refreshes failed in a row and the time the next attempt may run
+	MetadataFailures int        `gorm:"not null;default:0" json:"-"`
+	MetadataRetryAt  *time.Time `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (Film) TableName() string {
+	return "movies"
+}
+
+// Release types as numbered by TMDb
+const (
+	ReleasePremiere          = providers.ReleasePremiere
+	ReleaseTheatricalLimited = providers.ReleaseTheatricalLimited
+	ReleaseTheatrical        = providers.ReleaseTheatrical
+	ReleaseDigital           = providers.ReleaseDigital
+	ReleasePhysical          = providers.ReleasePhysical
+	ReleaseTV                = providers.ReleaseTV
+)
+
+var releaseTypeNames = map[int]string{
+	ReleasePremiere:          "premiere",
+	ReleaseTheatricalLimited: "theatrical_limited",
+	ReleaseTheatrical:        "theatrical",
+	ReleaseDigital:           "digital",
+	ReleasePhysical:          "physical",
+	ReleaseTV:                "tv",
+}
+
+// ReleaseDate is the release of a film in one country through one channel
+type ReleaseDate struct {
+	ID            uint   `gorm:"primary_key" json:"-"`
+	FilmID        uint   `gorm:"index" json:"-"`
+	Country       string `gorm:"size:2" json:"country"`
+	Type          int    `json:"type"`
+	Date          Date   `gorm:"type:date" json:"date"`
+	Certification string `json:"certification,omitempty"`
+	Note          string `json:"note,omitempty"`
+}
+
+func (ReleaseDate) TableName() string {
+	return "release_dates"
+}
+
+func (release ReleaseDate) MarshalJSON() ([]byte, error) {
+	type plain ReleaseDate
+	return json.Marshal(struct {
+		plain
+		TypeName string `json:"type_name"`
+	}{plain(release), releaseTypeNames[release.Type]})
+}
+
+// orderReleases sorts preloaded releases chronologically
+func orderReleases(db *gorm.DB) *gorm.DB {
+	return db.Order("date, country, type")
+}
+
+func (film *Film) UpdateFilm() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// The metadata backoff is only written by the metadata refresh
+		if err := tx.Omit("metadata_failures", "metadata_retry_at").Save(film).Error; err != nil {
+			return err
+		}
+		if err := saveReleases(tx, film); err != nil {
+			return err
+		}
+		return recordMovieChanges(tx, film.ID)
+	})
+}
+
+// saveReleases replaces the stored releases of the film when they have been fetched. Releases
+// without a country never replace per country ones, region preferences could not match them.
+func saveReleases(tx *gorm.DB, film *Film) error {
+	if film.Releases == nil {
+		return nil
+	}
+
+	withCountry := false
+	for _, release := range film.Releases {
+		withCountry = withCountry || release.Country != ""
+	}
+	if !withCountry {
+		var stored int
+		if err := tx.Model(&ReleaseDate{}).Where("film_id = ? AND country <> ?", film.ID, "").Count(&stored).Error; err != nil {
+			return err
+		}
+		if stored > 0 {
+			return nil
+		}
+	}
+
+	if err := tx.Where("film_id = ?", film.ID).Delete(&ReleaseDate{}).Error; err != nil {
+		return err
+	}
+
+	for i := range film.Releases {
+		film.Releases[i].ID = 0
+		film.Releases[i].FilmID = film.ID
+		if err := tx.Create(&film.Releases[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateReleaseDate fetches every release of the film from the metadata provider. The release date
+// of the film stays the earliest digital, physical or TV release of any country.
+func (film *Film) UpdateReleaseDate() {
+	movieReleases, err := Metadata.ReleaseDates(movieRef(film.ID))
+
+	now := time.Now().UTC()
+	film.ReleasesRefreshedAt = &now
+
+	if err != nil {
+		log.Println("Error retrieving movie release dates", err)
+		return
+	}
+
+	releases := []ReleaseDate{}
+	var releaseDate *Date = nil
+	for _, movieRelease := range movieReleases {
+		relDate := NewDate(movieRelease.Date)
+
+		releases = append(releases, ReleaseDate{
+			FilmID:        film.ID,
+			Country:       movieRelease.Country,
+			Type:          movieRelease.Type,
+			Date:          relDate,
+			Certification: movieRelease.Certification,
+			Note:          movieRelease.Note,
+		})
+
+		if movieRelease.Type > ReleaseTheatrical && (releaseDate == nil || releaseDate.After(relDate.Time)) {
+			date := relDate
+			releaseDate = &date
+		}
+	}
+
+	film.Releases = releases
+	if releaseDate != nil {
+		film.ReleaseDate = releaseDate
+	}
+}
+
+// EnsureFilm returns the shared film with the given TMDb ID creating it when no library has it yet
+func EnsureFilm(tmdbID uint, title string, image string) (*Film, error) {
+	var film Film
+
+	err := DB.First(&film, tmdbID).Error
+	if err == nil {
+		return &film, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	film = Film{ID: tmdbID, Title: title, Image: image}
+	film.UpdateReleaseDate()
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&film).Error; err != nil {
+			return err
+		}
+		return saveReleases(tx, &film)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &film, nil
+}
+
+// filmsNeedingReleases keeps the films whose releases were never fetched or were fetched before
+// the given time
+func filmsNeedingReleases(db *gorm.DB, staleBefore time.Time) *gorm.DB {
+	return db.Where("releases_refreshed_at IS NULL OR releases_refreshed_at < ?", staleBefore)
+}
+
+// unavailableFilmIDs returns the films of the user library that are not available yet according
+// to the preferences of the user and that the user has not been notified about
+func unavailableFilmIDs(uid uint, prefs ReleasePreferences, today time.Time) ([]uint, error) {
+	var ids []uint
+
+	err := DB.Model(&Movie{}).
+		Where("user_id = ? AND email_sent = ? AND movie_id NOT IN ?", uid, false, prefs.availableFilms(NewDate(today))).
+		Pluck("DISTINCT movie_id", &ids).Error
+
+	return ids, err
+}
+
+// GetFilmsNeedingReleases returns the films whose releases are stale and that are still not
+// available to at least one user having them in the library
+func GetFilmsNeedingReleases(staleBefore time.Time, today time.Time) ([]Film, error) {
+	var films []Film
+	var users []User
+
+	if err := DB.Select("id, regions, release_types").Find(&users).Error; err != nil {
+		return films, err
+	}
+
+	unique := map[uint]bool{}
+	ids := []uint{}
+	for _, user := range users {
+		unavailable, err := unavailableFilmIDs(user.ID, user.ReleasePreferences(), today)
+		if err != nil {
+			return films, err
+		}
+		for _, id := range unavailable {
+			if !unique[id] {
+				unique[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return films, nil
+	}
+
+	err := filmsNeedingReleases(DB, staleBefore).Where("id IN (?)", ids).Find(&films).Error
+
+	return films, err
+}
+
+// GetFilmsNeedingReleasesByUserID returns the films of the user library whose releases are stale
+// and that are not available to the user yet
+func GetFilmsNeedingReleasesByUserID(uid uint, staleBefore time.Time, today time.Time) ([]Film, error) {
+	var films []Film
+
+	prefs, err := GetReleasePreferencesByUserID(uid)
+	if err != nil {
+		return films, err
+	}
+
+	ids, err := unavailableFilmIDs(uid, prefs, today)
+	if err != nil || len(ids) == 0 {
+		return films, err
+	}
+
+	err = filmsNeedingReleases(DB, staleBefore).Where("id IN (?)", ids).Find(&films).Error
+
+	return films, err
+}
@@ -0,0 +1,90 @@
+package models
+
+import "movies-backend/providers"
+
+// LibraryStatus is the state of a movie in the library of a user. ID is the library entry.
+type LibraryStatus struct {
+	InLibrary   bool `json:"in_library"`
+	ID          uint `json:"id,omitempty"`
+	InWatchlist bool `json:"in_watchlist"`
+	Downloaded  bool `json:"downloaded"`
+	Watched     bool `json:"watched"`
+	Rating      uint `json:"rating"`
+}
+
+// AnnotatedMovie is a result of the metadata provider with the state of the movie in the library
+// of the caller, left out for anonymous callers
+type AnnotatedMovie struct {
+	providers.MovieSummary
+	Library *LibraryStatus `json:"library,omitempty"`
+}
+
+type AnnotatedMovieList struct {
+	Page         int64            `json:"page"`
+	TotalPages   int64            `json:"total_pages"`
+	TotalResults int64            `json:"total_results"`
+	Results      []AnnotatedMovie `json:"results"`
+}
+
+// NewAnnotatedMovieList copies the results of the provider without any library state
+func NewAnnotatedMovieList(list *providers.MovieList) *AnnotatedMovieList {
+	annotated := &AnnotatedMovieList{Page: list.Page, TotalPages: list.TotalPages, TotalResults: list.TotalResults, Results: []AnnotatedMovie{}}
+	for _, result := range list.Results {
+		annotated.Results = append(annotated.Results, AnnotatedMovie{MovieSummary: result})
+	}
+	return annotated
+}
+
+// Annotate sets the library state of every result from the entries of the user library
+func (annotated *AnnotatedMovieList) Annotate(movies []Movie) {
+	byMovieID := map[uint]Movie{}
+	for _, movie := range movies {
+		byMovieID[movie.MovieID] = movie
+	}
+
+	for i := range annotated.Results {
+		status := &LibraryStatus{}
+		if movie, found := byMovieID[annotated.Results[i].ID]; found {
+			status = &LibraryStatus{
+				InLibrary:   true,
+				ID:          movie.ID,
+				InWatchlist: !movie.Downloaded,
+				Downloaded:  movie.Downloaded,
+				Watched:     movie.Watched,
+				Rating:      movie.Rating,
+			}
+		}
+		annotated.Results[i].Library = status
+	}
+}
+
+// AnnotateMovieList adds the library state of every result for the user, 0 for anonymous callers
+func AnnotateMovieList(uid uint, list *providers.MovieList) (*AnnotatedMovieList, error) {
+	if list == nil {
+		return nil, nil
+	}
+
+	annotated := NewAnnotatedMovieList(list)
+
+	if uid == 0 {
+		return annotated, nil
+	}
+
+	var ids []uint
+	for _, result := range list.Results {
+		if result.ID != 0 {
+			ids = append(ids, result.ID)
+		}
+	}
+
+	var movies []Movie
+	if len(ids) > 0 {
+		if err := DB.Where("user_id = ? AND movie_id IN (?)", uid, ids).Find(&movies).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	annotated.Annotate(movies)
+
+	return annotated, nil
+}
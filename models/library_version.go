@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LibraryVersion identifies the state of a user library. It increases whenever an entry of the
+// library or the shared data shown with it changes.
+type LibraryVersion struct {
+	UserID    uint
+	Version   uint64
+	UpdatedAt time.Time
+}
+
+func (version LibraryVersion) ETag() string {
+	return fmt.Sprintf(`"%d-%d"`, version.UserID, version.Version)
+}
+
+func GetLibraryVersion(uid uint) (LibraryVersion, error) {
+	var u User
+
+	if err := DB.Select("id, library_version, library_updated_at").First(&u, uid).Error; err != nil {
+		return LibraryVersion{}, err
+	}
+
+	version := LibraryVersion{UserID: u.ID, Version: u.LibraryVersion}
+	if u.LibraryUpdatedAt != nil {
+		version.UpdatedAt = *u.LibraryUpdatedAt
+	}
+
+	return version, nil
+}
+
+// recordChanges bumps the library version of the user and stamps the changed entries with the
+// new version, which makes the version a change sequence for sync clients. It must run in the
+// transaction that changed the entries so no reader sees the change without its stamp.
+func recordChanges(db *gorm.DB, uid uint, ids []uint) (uint64, error) {
+	if uid == 0 {
+		// Bulk statements have no owner, they record their changes themselves
+		return 0, nil
+	}
+
+	err := db.Model(&User{}).Where("id = ?", uid).UpdateColumns(map[string]interface{}{
+		"library_version":    gorm.Expr("library_version + 1"),
+		"library_updated_at": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var u User
+	if err := db.Select("id, library_version").First(&u, uid).Error; err != nil {
+		return 0, err
+	}
+
+	if len(ids) > 0 {
+		if err := db.Unscoped().Model(&Movie{}).Where("id IN (?)", ids).UpdateColumn("change_seq", u.LibraryVersion).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return u.LibraryVersion, nil
+}
+
+// recordMovieChanges records a change of the shared data of a movie in every library having it
+func recordMovieChanges(db *gorm.DB, tmdbID uint) error {
+	var movies []Movie
+
+	if err := db.Unscoped().Select("id, user_id").Where("movie_id = ?", tmdbID).Find(&movies).Error; err != nil {
+		return err
+	}
+
+	byUser := map[uint][]uint{}
+	for _, movie := range movies {
+		byUser[movie.UserID] = append(byUser[movie.UserID], movie.ID)
+	}
+
+	for uid, ids := range byUser {
+		if _, err := recordChanges(db, uid, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Hooks run inside the transaction gorm opens for every write
+func (movie *Movie) AfterSave(scope *gorm.Scope) error {
+	return movie.recordChange(scope.NewDB())
+}
+
+func (movie *Movie) AfterDelete(scope *gorm.Scope) error {
+	return movie.recordChange(scope.NewDB())
+}
+
+func (movie *Movie) recordChange(db *gorm.DB) error {
+	if movie.ID == 0 {
+		return nil
+	}
+
+	version, err := recordChanges(db, movie.UserID, []uint{movie.ID})
+	if err != nil {
+		return err
+	}
+
+	if version > 0 {
+		movie.ChangeSeq = version
+	}
+	return nil
+}
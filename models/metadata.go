@@ -0,0 +1,289 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"movies-backend/providers"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Number of cast members kept for every movie
+const maxCastMembers = 10
+
+// Country whose certification is preferred when TMDb returns several
+const certificationCountry = "US"
+
+// Wait before retrying a failed metadata refresh, doubled on every failure up to maxMetadataBackoff
+const (
+	metadataBackoff    = time.Hour
+	maxMetadataBackoff = 7 * 24 * time.Hour
+)
+
+type CastMember struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Character   string `json:"character"`
+	ProfilePath string `json:"profile_path"`
+}
+
+// CastList is stored as a JSON encoded text column
+type CastList []CastMember
+
+func (cast CastList) Value() (driver.Value, error) {
+	if cast == nil {
+		return "[]", nil
+	}
+	value, err := json.Marshal(cast)
+	return string(value), err
+}
+
+func (cast *CastList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*cast = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, cast)
+	case string:
+		return json.Unmarshal([]byte(v), cast)
+	default:
+		return fmt.Errorf("cannot scan %T into CastList", value)
+	}
+}
+
+// MovieMetadata holds the details of the metadata provider shared by every user that has the movie in the library
+type MovieMetadata struct {
+	TMDbID           uint         `gorm:"column:tmdb_id;primary_key;auto_increment:false" json:"tmdb_id"`
+	IMDbID           string       `gorm:"column:imdb_id;size:16" json:"imdb_id"`
+	OriginalTitle    string       `json:"original_title"`
+	OriginalLanguage string       `gorm:"size:8" json:"original_language"`
+	Overview         string       `gorm:"type:text" json:"overview"`
+	Runtime          int          `json:"runtime"`
+	ReleaseYear      int          `json:"release_year"`
+	Certification    string       `gorm:"size:16" json:"certification"`
+	Cast             CastList     `gorm:"type:text" json:"cast"`
+	Genres           []MovieGenre `gorm:"foreignkey:TMDbID;association_foreignkey:TMDbID;save_associations:false" json:"genres"`
+	RefreshedAt      time.Time    `gorm:"index" json:"refreshed_at"`
+}
+
+func (MovieMetadata) TableName() string {
+	return "movie_metadata"
+}
+
+type MovieGenre struct {
+	ID      uint   `gorm:"primary_key" json:"-"`
+	TMDbID  uint   `gorm:"column:tmdb_id;index" json:"-"`
+	GenreID uint   `json:"id"`
+	Name    string `gorm:"size:64;index" json:"name"`
+}
+
+// withMetadata preloads the metadata of the movies returned by the query
+func withMetadata(db *gorm.DB) *gorm.DB {
+	return db.Preload("Metadata").Preload("Metadata.Genres")
+}
+
+// withGenre restricts the query to movies having the given genre
+func withGenre(db *gorm.DB, genre string) *gorm.DB {
+	if genre == "" {
+		return db
+	}
+	return db.Where("movie_id IN ?", DB.Table("movie_genres").Select("tmdb_id").Where("LOWER(name) = ?", strings.ToLower(genre)).SubQuery())
+}
+
+// releaseYear extracts the year of a YYYY-MM-DD date returning 0 when it is unknown
+func releaseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// pickCertification prefers the certification of certificationCountry and falls back to the first one found
+func pickCertification(releases []providers.Release) string {
+	fallback := ""
+	for _, release := range releases {
+		if release.Certification == "" {
+			continue
+		}
+		if release.Country == certificationCountry {
+			return release.Certification
+		}
+		if fallback == "" {
+			fallback = release.Certification
+		}
+	}
+
+	return fallback
+}
+
+// movieRef identifies a movie for the metadata provider adding its IMDb ID when it is cached
+func movieRef(tmdbID uint) providers.MovieRef {
+	ref := providers.MovieRef{TMDbID: tmdbID}
+
+	var imdbIDs []string
+	DB.Model(&MovieMetadata{}).Where("tmdb_id = ?", tmdbID).Pluck("imdb_id", &imdbIDs)
+	if len(imdbIDs) > 0 {
+		ref.IMDbID = imdbIDs[0]
+	}
+
+	return ref
+}
+
+// RefreshMovieMetadata fetches the movie details from the metadata provider and stores them in the shared cache table
+func RefreshMovieMetadata(tmdbID uint) (*MovieMetadata, error) {
+	details, err := Metadata.MovieDetails(movieRef(tmdbID), providers.DetailsOptions{Language: "en-US"})
+	if err != nil {
+		if err := recordMetadataFailure(tmdbID, time.Now().UTC()); err != nil {
+			log.Println("Error recording metadata failure", tmdbID, err)
+		}
+		return nil, err
+	}
+
+	metadata := MovieMetadata{
+		TMDbID:           tmdbID,
+		IMDbID:           details.IMDbID,
+		OriginalTitle:    details.OriginalTitle,
+		OriginalLanguage: details.OriginalLanguage,
+		Overview:         details.Overview,
+		Runtime:          details.Runtime,
+		ReleaseYear:      releaseYear(details.ReleaseDate),
+		Cast:             CastList{},
+		RefreshedAt:      time.Now().UTC(),
+	}
+
+	for _, genre := range details.Genres {
+		metadata.Genres = append(metadata.Genres, MovieGenre{TMDbID: tmdbID, GenreID: uint(genre.ID), Name: genre.Name})
+	}
+
+	for _, cast := range details.Cast {
+		if len(metadata.Cast) == maxCastMembers {
+			break
+		}
+		metadata.Cast = append(metadata.Cast, CastMember{ID: cast.ID, Name: cast.Name, Character: cast.Character, ProfilePath: cast.ProfilePath})
+	}
+
+	metadata.Certification = pickCertification(details.Releases)
+	if metadata.Certification == "" {
+		metadata.Certification = details.Certification
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&metadata).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("tmdb_id = ?", tmdbID).Delete(&MovieGenre{}).Error; err != nil {
+			return err
+		}
+
+		for i := range metadata.Genres {
+			if err := tx.Create(&metadata.Genres[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&Film{}).Where("id = ?", tmdbID).UpdateColumns(map[string]interface{}{
+			"metadata_failures": 0,
+			"metadata_retry_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		return recordMovieChanges(tx, tmdbID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// EnsureMovieMetadata returns the cached metadata of a movie and fetches it only if it is not cached yet
+func EnsureMovieMetadata(tmdbID uint) *MovieMetadata {
+	var metadata MovieMetadata
+
+	err := DB.Preload("Genres").First(&metadata, "tmdb_id = ?", tmdbID).Error
+	if err == nil {
+		return &metadata
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Error reading movie metadata", tmdbID, err)
+		return nil
+	}
+
+	refreshed, err := RefreshMovieMetadata(tmdbID)
+	if err != nil {
+		log.Println("Error retrieving movie metadata", tmdbID, err)
+		return nil
+	}
+
+	return refreshed
+}
+
+// recordMetadataFailure postpones the next metadata refresh of the film, waiting longer after
+// every failure so movies the provider never finds do not crowd out the others
+func recordMetadataFailure(tmdbID uint, now time.Time) error {
+	var film Film
+	if err := DB.Select("id, metadata_failures").First(&film, tmdbID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	wait := metadataBackoff
+	for i := 0; i < film.MetadataFailures && wait < maxMetadataBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxMetadataBackoff {
+		wait = maxMetadataBackoff
+	}
+
+	return DB.Model(&Film{}).Where("id = ?", tmdbID).UpdateColumns(map[string]interface{}{
+		"metadata_failures": film.MetadataFailures + 1,
+		"metadata_retry_at": now.Add(wait),
+	}).Error
+}
+
+// GetMovieIDsNeedingMetadata returns TMDb IDs in any library that have no metadata or whose
+// metadata was refreshed before the given time, skipping the ones backing off after a failure.
+// Movies without metadata come first, then the ones refreshed the longest ago.
+func GetMovieIDsNeedingMetadata(staleBefore time.Time, now time.Time, limit int) ([]uint, error) {
+	var ids []uint
+
+	rows, err := DB.Table(Film{}.TableName()).
+		Select("movies.id").
+		Joins("LEFT JOIN movie_metadata ON movie_metadata.tmdb_id = movies.id").
+		Where("movies.id IN ?", DB.Model(&Movie{}).Select("movie_id").SubQuery()).
+		Where("movie_metadata.tmdb_id IS NULL OR movie_metadata.refreshed_at < ?", staleBefore).
+		Where("movies.metadata_retry_at IS NULL OR movies.metadata_retry_at <= ?", now).
+		Order("CASE WHEN movie_metadata.tmdb_id IS NULL THEN 0 ELSE 1 END, movie_metadata.refreshed_at, movies.id").
+		Limit(limit).
+		Rows()
+	if err != nil {
+		return ids, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -1,10 +1,13 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/jinzhu/gorm"
 )
 
 type Tabler interface {
@@ -12,102 +15,198 @@ type Tabler interface {
 }
 
 var ErrMovieNotOwned = errors.New("you can only delete your own movie")
+var ErrMovieNotInTrash = errors.New("movie is not in the trash")
+var ErrMovieNotInWatchlist = errors.New("movie is not in the watchlist")
+var ErrStaleMovie = errors.New("movie was changed by another request, reload it and try again")
+
+// Ranks longer than this trigger a renumbering of the whole watchlist
+const MaxPositionLength = 64
+
+// Number of times a background job retries an update that lost against a concurrent writer
+const staleRetries = 3
 
 // TableName overrides the table name used by User to `profiles`
 func (Movie) TableName() string {
-	return "watchlist"
+	return "user_movies"
 }
 
+// Movie is an entry of a user library. Title, release date and image live in the shared
+// Film and are copied to the entry when it is loaded so the API responses keep their shape.
 type Movie struct {
-	ID          uint    `gorm:"primary_key" json:"id"`
-	UserID      uint    `json:"user_id"`
-	Title       string  `json:"title"`
-	ReleaseDate *string `json:"release_date"`
-	Image       string  `json:"image"`
-	MovieID     uint    `json:"movie_id"`
-	EmailSent   bool    `json:"email_sent"`
-	Downloaded  bool    `gorm:"default:false" json:"downloaded"`
-	Watched     bool    `gorm:"default:false" json:"watched"`
-	Rating      uint    `gorm:"default:0" json:"rating"`
-}
-
-func GetWatchlistByUserID(uid uint) ([]Movie, error) {
+	ID          uint          `gorm:"primary_key" json:"id"`
+	UserID      uint          `gorm:"index" json:"user_id"`
+	Title       string        `gorm:"-" json:"title"`
+	ReleaseDate *Date         `gorm:"-" json:"release_date"`
+	Image       string        `gorm:"-" json:"image"`
+	Releases    []ReleaseDate `gorm:"-" json:"releases,omitempty"`
+	AvailableOn *Date         `gorm:"-" json:"available_on,omitempty"`
+	MovieID     uint          `gorm:"index" json:"movie_id"`
+	Film        *Film         `gorm:"foreignkey:MovieID;save_associations:false" json:"-"`
+	EmailSent   bool          `json:"email_sent"`
+	Downloaded  bool          `gorm:"default:false" json:"downloaded"`
+	Watched     bool          `gorm:"default:false" json:"watched"`
+	Rating      uint          `gorm:"default:0" json:"rating"`
+	Position    string        `gorm:"size:255;index" json:"position"`
+	WatchedAt   *time.Time    `json:"watched_at,omitempty"`
+	ChangeSeq   uint64        `gorm:"not null;default:0;index" json:"change_seq"`
+	// Incremented on every update, writes only succeed on the version they were loaded with
+	Version   uint           `gorm:"not null;default:1" json:"version"`
+	Metadata  *MovieMetadata `gorm:"foreignkey:MovieID;association_foreignkey:TMDbID;save_associations:false" json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt *time.Time     `sql:"index" json:"deleted_at,omitempty"`
+}
+
+func (movie *Movie) AfterFind() {
+	if movie.Film != nil {
+		movie.Title = movie.Film.Title
+		movie.ReleaseDate = movie.Film.ReleaseDate
+		movie.Image = movie.Film.Image
+		movie.Releases = movie.Film.Releases
+	}
+}
+
+// withFilm preloads the shared film of the movies returned by the query
+func withFilm(db *gorm.DB) *gorm.DB {
+	return db.Preload("Film").Preload("Film.Releases", orderReleases)
+}
+
+// LibraryFilter narrows down the movies returned by the library listings
+type LibraryFilter struct {
+	Genre string
+}
+
+func GetWatchlistByUserID(uid uint, filter LibraryFilter) ([]Movie, error) {
 	var movies []Movie
 
-	if err := DB.Find(&movies, "user_id = ? AND downloaded = ?", uid, false).Error; err != nil {
+	if err := withGenre(withMetadata(withFilm(DB)), filter.Genre).Order("position, id").Find(&movies, "user_id = ? AND downloaded = ?", uid, false).Error; err != nil {
 		return movies, fmt.Errorf("watchlist for user id %d not found", uid)
 	}
 
+	applyReleasePreferences(uid, movies)
+
 	return movies, nil
 }
 
-func GetMoviesByUserID(uid uint) ([]Movie, error) {
+func GetMoviesByUserID(uid uint, filter LibraryFilter) ([]Movie, error) {
 	var movies []Movie
 
-	if err := DB.Find(&movies, "user_id = ? AND downloaded = ?", uid, true).Error; err != nil {
+	if err := withGenre(withMetadata(withFilm(DB)), filter.Genre).Find(&movies, "user_id = ? AND downloaded = ?", uid, true).Error; err != nil {
 		return movies, fmt.Errorf("movies for user id %d not found", uid)
 	}
 
+	applyReleasePreferences(uid, movies)
+
 	return movies, nil
 }
 
-func (movie *Movie) UpdateMovie() error {
-	return DB.Save(&movie).Error
+// updateMovieInTx writes the values only when the entry still has the version it was loaded
+// with and bumps the version. It fails with ErrStaleMovie when another writer changed it first.
+func updateMovieInTx(tx *gorm.DB, movie *Movie, values map[string]interface{}) error {
+	values["version"] = gorm.Expr("version + 1")
+
+	result := tx.Unscoped().Model(movie).Where("version = ?", movie.Version).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleMovie
+	}
+
+	movie.Version++
+	return nil
 }
 
-func (movie *Movie) UpdateReleaseDate() {
-	movieInfo, err := TMDbClient.GetMovieReleaseDates(int(movie.MovieID))
+// updateMovie runs updateMovieInTx in its own transaction so a stale write leaves nothing behind
+func updateMovie(movie *Movie, values map[string]interface{}) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return updateMovieInTx(tx, movie, values)
+	})
+}
 
-	if err != nil {
-		log.Println("Error retrieving movie release dates", err)
-	} else {
-		var releaseDate *time.Time = nil
-		for _, result := range movieInfo.MovieReleaseDatesResults.Results {
-			for _, movieReleaseDate := range result.ReleaseDates {
-				if movieReleaseDate.Type > 3 {
-					relDate, err := time.Parse(time.RFC3339Nano, movieReleaseDate.ReleaseDate)
-					if err != nil {
-						log.Println("Error decoding date", movieReleaseDate.ReleaseDate)
-					} else {
-						if releaseDate == nil {
-							releaseDate = &relDate
-						} else {
-							if releaseDate.After(relDate) {
-								releaseDate = &relDate
-							}
-						}
-					}
-				}
-			}
+// atVersion makes the next update fail with ErrStaleMovie unless the entry is still at the version
+// the client read it at. Version 0 skips the check.
+func (movie *Movie) atVersion(version uint) {
+	if version != 0 {
+		movie.Version = version
+	}
+}
+
+// retryStale runs the update and, while it fails because the entry changed in the meantime,
+// loads the entry again and reruns it on the current state
+func retryStale(movie *Movie, update func(*Movie) error) error {
+	for attempt := 1; ; attempt++ {
+		err := update(movie)
+		if !errors.Is(err, ErrStaleMovie) || attempt == staleRetries {
+			return err
 		}
 
-		if releaseDate != nil {
-			formattedDate := releaseDate.Format("2006-01-02")
-			movie.ReleaseDate = &formattedDate
+		var current Movie
+		if err := withFilm(DB.Unscoped()).First(&current, movie.ID).Error; err != nil {
+			return err
 		}
+		*movie = current
 	}
 }
 
-func GetMoviesWithoutReleaseDateByUserID(uid uint) []Movie {
-	var wl []Movie
+// GetMoviesToNotifyByUserID returns the movies that are available according to the preferences
+// of the user but no email notification was sent for
+func GetMoviesToNotifyByUserID(uid uint, prefs ReleasePreferences, today time.Time) ([]Movie, error) {
+	var movies []Movie
 
-	DB.Where("release_date IS NULL").Find(&wl, "user_id = ?", uid)
+	err := withFilm(DB).
+		Where("email_sent = ? AND movie_id IN ?", false, prefs.availableFilms(NewDate(today))).
+		Find(&movies, "user_id = ?", uid).Error
+	for i := range movies {
+		movies[i].AvailableOn = prefs.AvailableOn(movies[i].Releases)
+	}
 
-	return wl
+	return movies, err
+}
+
+// MarkMoviesAsEmailSent flags the movies one by one, retrying the ones a user changed meanwhile.
+// A movie that cannot be flagged is logged and the others are still flagged.
+func MarkMoviesAsEmailSent(movies []Movie) {
+	for i := range movies {
+		err := retryStale(&movies[i], func(movie *Movie) error {
+			if movie.EmailSent {
+				return nil
+			}
+			return updateMovie(movie, map[string]interface{}{"email_sent": true})
+		})
+		if err != nil {
+			log.Println("Error marking movie as email sent", movies[i].ID, err)
+		}
+	}
 }
 
 func (movie *Movie) SaveMovieToWatchlist() (*Movie, error) {
-	movie.UpdateReleaseDate()
+	film, err := EnsureFilm(movie.MovieID, movie.Title, movie.Image)
+	if err != nil {
+		return &Movie{}, err
+	}
+	movie.Film = film
+
+	last, err := lastWatchlistPosition(DB, movie.UserID)
+	if err != nil {
+		return &Movie{}, err
+	}
+	movie.Position = RankBetween(last, "")
+
 	if err := DB.Create(&movie).Error; err != nil {
 		return &Movie{}, err
 	}
+	movie.AfterFind()
+	RecordActivity(movie.UserID, ActivityAdded, movie)
+
+	movie.Metadata = EnsureMovieMetadata(movie.MovieID)
 	return movie, nil
 }
 
-func DeleteMovieFromWatchlistByID(id string, uid uint) error {
+func DeleteMovieFromWatchlistByID(id string, uid uint, version uint) error {
 	var wl Movie
 
-	if err := DB.First(&wl, id).Error; err != nil {
+	if err := withFilm(DB).First(&wl, id).Error; err != nil {
 		return err
 	}
 
@@ -115,17 +214,118 @@ func DeleteMovieFromWatchlistByID(id string, uid uint) error {
 		return ErrMovieNotOwned
 	}
 
-	if err := DB.Delete(&wl).Error; err != nil {
+	// Setting DeletedAt only moves the row to the trash
+	wl.atVersion(version)
+	if err := updateMovie(&wl, map[string]interface{}{"deleted_at": time.Now().UTC()}); err != nil {
 		return err
 	}
 
+	RecordActivity(uid, ActivityDeleted, &wl)
+
 	return nil
 }
 
-func MarkMovieAsDownloadedByID(id string, uid uint) error {
+func GetTrashByUserID(uid uint) ([]Movie, error) {
+	var movies []Movie
+
+	if err := withMetadata(withFilm(DB.Unscoped())).Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&movies, "user_id = ?", uid).Error; err != nil {
+		return movies, fmt.Errorf("trash for user id %d not found", uid)
+	}
+
+	return movies, nil
+}
+
+func RestoreMovieFromTrashByID(id string, uid uint, version uint) (*Movie, error) {
 	var wl Movie
 
-	if err := DB.First(&wl, id).Error; err != nil {
+	if err := withFilm(DB.Unscoped()).First(&wl, id).Error; err != nil {
+		return nil, err
+	}
+
+	if wl.UserID != uid {
+		return nil, ErrMovieNotOwned
+	}
+
+	if wl.DeletedAt == nil {
+		return nil, ErrMovieNotInTrash
+	}
+
+	wl.atVersion(version)
+	if err := updateMovie(&wl, map[string]interface{}{"deleted_at": nil}); err != nil {
+		return nil, err
+	}
+
+	RecordActivity(uid, ActivityRestored, &wl)
+
+	return &wl, nil
+}
+
+// PurgeTrash permanently deletes movies that were moved to the trash before the given time.
+// Sync clients that have not seen those deletions yet are told to start over.
+func PurgeTrash(before time.Time) (int64, error) {
+	var purged int64
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Unscoped().Model(&Movie{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+
+		rows, err := expired.Select("user_id, MAX(change_seq)").Group("user_id").Rows()
+		if err != nil {
+			return err
+		}
+		floors := map[uint]uint64{}
+		for rows.Next() {
+			var uid uint
+			var seq uint64
+			if err := rows.Scan(&uid, &seq); err != nil {
+				rows.Close()
+				return err
+			}
+			floors[uid] = seq
+		}
+		rows.Close()
+
+		for uid, seq := range floors {
+			if err := tx.Model(&User{}).Where("id = ? AND purged_change_seq < ?", uid, seq).UpdateColumn("purged_change_seq", seq).Error; err != nil {
+				return err
+			}
+		}
+
+		result := expired.Delete(&Movie{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+
+	return purged, err
+}
+
+// GetLibraryEntryByID returns an entry of the user library, trash included
+func GetLibraryEntryByID(id uint, uid uint) (Movie, error) {
+	var wl Movie
+
+	if err := withFilm(DB.Unscoped()).First(&wl, id).Error; err != nil {
+		return wl, err
+	}
+
+	if wl.UserID != uid {
+		return wl, ErrMovieNotOwned
+	}
+
+	return wl, nil
+}
+
+// GetLibraryEntryByMovieID returns the entry of the user library for a TMDb ID, trash excluded
+func GetLibraryEntryByMovieID(uid uint, tmdbID uint) (Movie, error) {
+	var wl Movie
+
+	err := withFilm(DB).Where("user_id = ? AND movie_id = ?", uid, tmdbID).First(&wl).Error
+
+	return wl, err
+}
+
+func MarkMovieAsDownloadedByID(id string, uid uint, version uint) error {
+	var wl Movie
+
+	if err := withFilm(DB).First(&wl, id).Error; err != nil {
 		return err
 	}
 
@@ -133,17 +333,20 @@ func MarkMovieAsDownloadedByID(id string, uid uint) error {
 		return ErrMovieNotOwned
 	}
 
-	if err := DB.Model(&wl).Update("downloaded", true).Error; err != nil {
+	wl.atVersion(version)
+	if err := updateMovie(&wl, map[string]interface{}{"downloaded": true}); err != nil {
 		return err
 	}
 
+	RecordActivity(uid, ActivityDownloaded, &wl)
+
 	return nil
 }
 
-func MarkMovieAsWatchedByID(id string, uid uint) error {
+func MarkMovieAsWatchedByID(id string, uid uint, version uint) error {
 	var wl Movie
 
-	if err := DB.First(&wl, id).Error; err != nil {
+	if err := withFilm(DB).First(&wl, id).Error; err != nil {
 		return err
 	}
 
@@ -151,17 +354,20 @@ func MarkMovieAsWatchedByID(id string, uid uint) error {
 		return ErrMovieNotOwned
 	}
 
-	if err := DB.Model(&wl).Update("watched", true).Error; err != nil {
+	wl.atVersion(version)
+	if err := updateMovie(&wl, map[string]interface{}{"watched": true, "watched_at": time.Now().UTC()}); err != nil {
 		return err
 	}
 
+	RecordActivity(uid, ActivityWatched, &wl)
+
 	return nil
 }
 
-func RateMovieByID(id string, uid uint, rating uint) error {
+func RateMovieByID(id string, uid uint, rating uint, version uint) error {
 	var wl Movie
 
-	if err := DB.First(&wl, id).Error; err != nil {
+	if err := withFilm(DB).First(&wl, id).Error; err != nil {
 		return err
 	}
 
@@ -169,9 +375,276 @@ func RateMovieByID(id string, uid uint, rating uint) error {
 		return ErrMovieNotOwned
 	}
 
-	if err := DB.Model(&wl).Update("rating", rating).Error; err != nil {
+	wl.atVersion(version)
+	if err := updateMovie(&wl, map[string]interface{}{"rating": rating}); err != nil {
 		return err
 	}
 
+	RecordActivity(uid, ActivityRated, &wl)
+
 	return nil
 }
+
+// WatchlistMove places the watchlist entry ID right after AfterID, or at the top when AfterID is 0.
+// Version is the version of the moved entry the client read, 0 skips the check.
+type WatchlistMove struct {
+	ID      uint `json:"id"`
+	AfterID uint `json:"after_id"`
+	Version uint `json:"version"`
+}
+
+func lastWatchlistPosition(tx *gorm.DB, uid uint) (string, error) {
+	var last sql.NullString
+
+	row := tx.Model(&Movie{}).Where("user_id = ? AND downloaded = ?", uid, false).Select("MAX(position)").Row()
+	if err := row.Scan(&last); err != nil {
+		return "", err
+	}
+
+	return last.String, nil
+}
+
+// renumberWatchlist spreads the positions of the whole watchlist evenly keeping the current order.
+// When onlyIfUnranked is set it does nothing unless some entries have no position yet.
+func renumberWatchlist(tx *gorm.DB, uid uint, onlyIfUnranked bool) error {
+	if onlyIfUnranked {
+		var unranked int
+		if err := tx.Model(&Movie{}).Where("user_id = ? AND downloaded = ? AND position = ?", uid, false, "").Count(&unranked).Error; err != nil {
+			return err
+		}
+		if unranked == 0 {
+			return nil
+		}
+	}
+
+	var movies []Movie
+	if err := tx.Order("position, id").Find(&movies, "user_id = ? AND downloaded = ?", uid, false).Error; err != nil {
+		return err
+	}
+
+	var ids []uint
+	for i, rank := range SpreadRanks(len(movies)) {
+		if err := tx.Model(&movies[i]).UpdateColumns(map[string]interface{}{"position": rank, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		ids = append(ids, movies[i].ID)
+	}
+
+	_, err := recordChanges(tx, uid, ids)
+	return err
+}
+
+func watchlistEntry(tx *gorm.DB, id uint, uid uint) (Movie, error) {
+	var wl Movie
+
+	if err := tx.First(&wl, id).Error; err != nil {
+		return wl, err
+	}
+
+	if wl.UserID != uid {
+		return wl, ErrMovieNotOwned
+	}
+
+	if wl.Downloaded {
+		return wl, ErrMovieNotInWatchlist
+	}
+
+	return wl, nil
+}
+
+// rankAfter computes a position right after the given one, skipping the entry that is being moved
+func rankAfter(tx *gorm.DB, uid uint, movingID uint, lower string) (string, error) {
+	var next []Movie
+
+	if err := tx.Order("position, id").Limit(1).Find(&next, "user_id = ? AND downloaded = ? AND id <> ? AND position > ?", uid, false, movingID, lower).Error; err != nil {
+		return "", err
+	}
+
+	upper := ""
+	if len(next) > 0 {
+		upper = next[0].Position
+	}
+
+	return RankBetween(lower, upper), nil
+}
+
+func moveWatchlistEntry(tx *gorm.DB, uid uint, move WatchlistMove) error {
+	wl, err := watchlistEntry(tx, move.ID, uid)
+	if err != nil {
+		return err
+	}
+
+	lower := ""
+	if move.AfterID != 0 {
+		after, err := watchlistEntry(tx, move.AfterID, uid)
+		if err != nil {
+			return err
+		}
+		lower = after.Position
+	}
+
+	position, err := rankAfter(tx, uid, wl.ID, lower)
+	if err != nil {
+		return err
+	}
+
+	if len(position) > MaxPositionLength {
+		// Renumbering leaves short positions so the retry cannot end up here again
+		if err := renumberWatchlist(tx, uid, false); err != nil {
+			return err
+		}
+		return moveWatchlistEntry(tx, uid, move)
+	}
+
+	return updateMovieInTx(tx, &wl, map[string]interface{}{"position": position})
+}
+
+// ReorderWatchlist applies the moves in order. Only the moved entries are written unless
+// the watchlist has never been ordered before or its positions grew too long. Versions are
+// checked before anything is written since renumbering and earlier moves bump them.
+func ReorderWatchlist(uid uint, moves []WatchlistMove) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, move := range moves {
+			if move.Version == 0 {
+				continue
+			}
+			wl, err := watchlistEntry(tx, move.ID, uid)
+			if err != nil {
+				return err
+			}
+			if wl.Version != move.Version {
+				return ErrStaleMovie
+			}
+		}
+
+		if err := renumberWatchlist(tx, uid, true); err != nil {
+			return err
+		}
+
+		for _, move := range moves {
+			if err := moveWatchlistEntry(tx, uid, move); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ImportedMovie is the state of a movie read from the export of another service
+type ImportedMovie struct {
+	TMDbID    uint
+	Title     string
+	Image     string
+	Watched   bool
+	WatchedAt *time.Time
+	Rating    uint
+}
+
+// ImportOutcome tells what importing a movie did to the user library
+type ImportOutcome int
+
+const (
+	ImportCreated ImportOutcome = iota
+	ImportRestored
+	ImportUpdated
+	ImportUnchanged
+)
+
+// getLibraryEntryWithTrash returns the entry of the user library for a TMDb ID, preferring the
+// entry outside the trash and otherwise the one trashed last
+func getLibraryEntryWithTrash(uid uint, tmdbID uint) (Movie, error) {
+	var wl Movie
+
+	err := withFilm(DB.Unscoped()).
+		Where("user_id = ? AND movie_id = ?", uid, tmdbID).
+		Order("CASE WHEN deleted_at IS NULL THEN 0 ELSE 1 END, deleted_at DESC").
+		First(&wl).Error
+
+	return wl, err
+}
+
+// ImportMovie adds the movie to the user library, restores it from the trash or updates the entry
+// already there. Watched movies are moved out of the watchlist.
+func ImportMovie(uid uint, imported ImportedMovie) (ImportOutcome, error) {
+	outcome := ImportUnchanged
+
+	wl, err := getLibraryEntryWithTrash(uid, imported.TMDbID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		wl = Movie{UserID: uid, MovieID: imported.TMDbID, Title: imported.Title, Image: imported.Image}
+		if _, err := wl.SaveMovieToWatchlist(); err != nil {
+			return outcome, err
+		}
+		outcome = ImportCreated
+	} else if err != nil {
+		return outcome, err
+	}
+
+	if wl.DeletedAt != nil {
+		if err := updateMovie(&wl, map[string]interface{}{"deleted_at": nil}); err != nil {
+			return outcome, err
+		}
+		wl.DeletedAt = nil
+		outcome = ImportRestored
+
+		RecordActivity(uid, ActivityRestored, &wl)
+	}
+
+	if imported.Watched && !wl.Watched {
+		watchedAt := time.Now().UTC()
+		if imported.WatchedAt != nil {
+			watchedAt = *imported.WatchedAt
+		}
+
+		if err := updateMovie(&wl, map[string]interface{}{"downloaded": true, "watched": true, "watched_at": watchedAt}); err != nil {
+			return outcome, err
+		}
+		wl.Downloaded, wl.Watched, wl.WatchedAt = true, true, &watchedAt
+		if outcome == ImportUnchanged {
+			outcome = ImportUpdated
+		}
+
+		RecordActivity(uid, ActivityWatched, &wl)
+	}
+
+	if imported.Rating > 0 && imported.Rating != wl.Rating {
+		if err := updateMovie(&wl, map[string]interface{}{"rating": imported.Rating}); err != nil {
+			return outcome, err
+		}
+		wl.Rating = imported.Rating
+		if outcome == ImportUnchanged {
+			outcome = ImportUpdated
+		}
+
+		RecordActivity(uid, ActivityRated, &wl)
+	}
+
+	return outcome, nil
+}
+
+// ForEachMovieByUserID walks the whole user library in batches ordered by ID so it can be
+// streamed without loading every row at once
+func ForEachMovieByUserID(uid uint, batchSize int, fn func([]Movie) error) error {
+	var lastID uint
+
+	for {
+		var movies []Movie
+
+		if err := withMetadata(withFilm(DB)).Where("user_id = ? AND id > ?", uid, lastID).Order("id").Limit(batchSize).Find(&movies).Error; err != nil {
+			return err
+		}
+
+		if len(movies) == 0 {
+			return nil
+		}
+
+		if err := fn(movies); err != nil {
+			return err
+		}
+
+		if len(movies) < batchSize {
+			return nil
+		}
+		lastID = movies[len(movies)-1].ID
+	}
+}
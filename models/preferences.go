@@ -0,0 +1,250 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrInvalidRegion      = errors.New("regions must be ISO 3166-1 alpha-2 country codes")
+	ErrInvalidReleaseType = errors.New("release types must be between 1 and 6")
+)
+
+// Language of the metadata for users that have not chosen one
+const defaultLanguage = "en-US"
+
+// Release types counted as available when the user has not chosen any
+var defaultReleaseTypes = []int{ReleaseDigital, ReleasePhysical, ReleaseTV}
+
+// ReleasePreferences decide which releases make a movie available to a user. No regions
+// means a release in any country counts.
+type ReleasePreferences struct {
+	Regions      []string `json:"regions"`
+	ReleaseTypes []int    `json:"release_types"`
+}
+
+// ReleasePreferences returns the stored preferences of the user with defaults applied
+func (u User) ReleasePreferences() ReleasePreferences {
+	prefs := ReleasePreferences{Regions: []string{}, ReleaseTypes: []int{}}
+
+	for _, region := range strings.Split(u.Regions, ",") {
+		if region != "" {
+			prefs.Regions = append(prefs.Regions, region)
+		}
+	}
+
+	for _, value := range strings.Split(u.ReleaseTypes, ",") {
+		if releaseType, err := strconv.Atoi(value); err == nil {
+			prefs.ReleaseTypes = append(prefs.ReleaseTypes, releaseType)
+		}
+	}
+
+	if len(prefs.ReleaseTypes) == 0 {
+		prefs.ReleaseTypes = append(prefs.ReleaseTypes, defaultReleaseTypes...)
+	}
+
+	return prefs
+}
+
+// Preferences are the settings of a user
+type Preferences struct {
+	ReleasePreferences
+	Language string `json:"language"`
+}
+
+// PreferencesUpdate replaces the release preferences of a user. The language is left unchanged
+// when nil and an empty language restores the default.
+type PreferencesUpdate struct {
+	ReleasePreferences
+	Language *string
+}
+
+// PreferredLanguage returns the language the user wants metadata in
+func (u User) PreferredLanguage() string {
+	if u.Language == "" {
+		return defaultLanguage
+	}
+	return u.Language
+}
+
+// normalize validates the preferences and returns them upper cased, sorted and without duplicates
+func (prefs ReleasePreferences) normalize() (ReleasePreferences, error) {
+	normalized := ReleasePreferences{Regions: []string{}, ReleaseTypes: []int{}}
+
+	regions := map[string]bool{}
+	for _, region := range prefs.Regions {
+		region = strings.ToUpper(strings.TrimSpace(region))
+		if len(region) != 2 || region[0] < 'A' || region[0] > 'Z' || region[1] < 'A' || region[1] > 'Z' {
+			return normalized, ErrInvalidRegion
+		}
+		if !regions[region] {
+			regions[region] = true
+			normalized.Regions = append(normalized.Regions, region)
+		}
+	}
+
+	releaseTypes := map[int]bool{}
+	for _, releaseType := range prefs.ReleaseTypes {
+		if releaseType < ReleasePremiere || releaseType > ReleaseTV {
+			return normalized, ErrInvalidReleaseType
+		}
+		if !releaseTypes[releaseType] {
+			releaseTypes[releaseType] = true
+			normalized.ReleaseTypes = append(normalized.ReleaseTypes, releaseType)
+		}
+	}
+
+	sort.Strings(normalized.Regions)
+	sort.Ints(normalized.ReleaseTypes)
+
+	return normalized, nil
+}
+
+// Matches reports whether the release counts as available for the preferences
+func (prefs ReleasePreferences) Matches(release ReleaseDate) bool {
+	matchesType := false
+	for _, releaseType := range prefs.ReleaseTypes {
+		if release.Type == releaseType {
+			matchesType = true
+			break
+		}
+	}
+	if !matchesType {
+		return false
+	}
+
+	if len(prefs.Regions) == 0 {
+		return true
+	}
+
+	for _, region := range prefs.Regions {
+		if release.Country == region {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AvailableOn returns the earliest of the releases matching the preferences
+func (prefs ReleasePreferences) AvailableOn(releases []ReleaseDate) *Date {
+	var availableOn *Date
+
+	for _, release := range releases {
+		if prefs.Matches(release) && (availableOn == nil || availableOn.After(release.Date.Time)) {
+			date := release.Date
+			availableOn = &date
+		}
+	}
+
+	return availableOn
+}
+
+// availableFilms selects the films with a release matching the preferences up to the given day
+func (prefs ReleasePreferences) availableFilms(today Date) interface{} {
+	query := DB.Model(&ReleaseDate{}).Select("film_id").Where("date <= ? AND type IN (?)", today, prefs.ReleaseTypes)
+	if len(prefs.Regions) > 0 {
+		query = query.Where("country IN (?)", prefs.Regions)
+	}
+
+	return query.SubQuery()
+}
+
+// Preferences returns the stored settings of the user with defaults applied
+func (u User) Preferences() Preferences {
+	return Preferences{ReleasePreferences: u.ReleasePreferences(), Language: u.PreferredLanguage()}
+}
+
+// SetPreferences validates the preferences and stores them in the columns of the user
+func (u *User) SetPreferences(input PreferencesUpdate) error {
+	prefs, err := input.ReleasePreferences.normalize()
+	if err != nil {
+		return err
+	}
+
+	releaseTypes := make([]string, len(prefs.ReleaseTypes))
+	for i, releaseType := range prefs.ReleaseTypes {
+		releaseTypes[i] = strconv.Itoa(releaseType)
+	}
+
+	u.Regions = strings.Join(prefs.Regions, ",")
+	u.ReleaseTypes = strings.Join(releaseTypes, ",")
+	if input.Language != nil {
+		u.Language = *input.Language
+	}
+
+	return nil
+}
+
+func GetPreferencesByUserID(uid uint) (Preferences, error) {
+	var u User
+
+	if err := DB.Select("id, regions, release_types, language").First(&u, uid).Error; err != nil {
+		return Preferences{}, err
+	}
+
+	return u.Preferences(), nil
+}
+
+func GetReleasePreferencesByUserID(uid uint) (ReleasePreferences, error) {
+	var u User
+
+	if err := DB.Select("id, regions, release_types").First(&u, uid).Error; err != nil {
+		return ReleasePreferences{}, err
+	}
+
+	return u.ReleasePreferences(), nil
+}
+
+// UpdatePreferencesByUserID stores the preferences of the user. Every library entry is recorded
+// as changed since its availability may differ.
+func UpdatePreferencesByUserID(uid uint, input PreferencesUpdate) (Preferences, error) {
+	var u User
+	if err := u.SetPreferences(input); err != nil {
+		return Preferences{}, err
+	}
+
+	columns := map[string]interface{}{
+		"regions":       u.Regions,
+		"release_types": u.ReleaseTypes,
+	}
+	if input.Language != nil {
+		columns["language"] = u.Language
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", uid).UpdateColumns(columns).Error
+		if err != nil {
+			return err
+		}
+
+		var ids []uint
+		if err := tx.Model(&Movie{}).Where("user_id = ?", uid).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		_, err = recordChanges(tx, uid, ids)
+		return err
+	})
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	return GetPreferencesByUserID(uid)
+}
+
+// applyReleasePreferences sets when each movie becomes available to its owner
+func applyReleasePreferences(uid uint, movies []Movie) {
+	prefs, err := GetReleasePreferencesByUserID(uid)
+	if err != nil {
+		return
+	}
+
+	for i := range movies {
+		movies[i].AvailableOn = prefs.AvailableOn(movies[i].Releases)
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ProviderResponse is a response of the metadata provider persisted by the cache. Keys are
+// hashed as search keys contain the query and can be longer than a primary key allows.
+type ProviderResponse struct {
+	CacheKey  string    `gorm:"primary_key;size:64"`
+	Body      string    `gorm:"type:text"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (ProviderResponse) TableName() string {
+	return "provider_responses"
+}
+
+// ProviderResponseStore keeps the responses of the metadata provider cache in the database
+type ProviderResponseStore struct{}
+
+func providerResponseKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (ProviderResponseStore) Load(key string) ([]byte, time.Time, bool, error) {
+	var response ProviderResponse
+
+	err := DB.First(&response, "cache_key = ?", providerResponseKey(key)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	return []byte(response.Body), response.ExpiresAt, true, nil
+}
+
+func (ProviderResponseStore) Save(key string, value []byte, expiresAt time.Time) error {
+	return DB.Save(&ProviderResponse{CacheKey: providerResponseKey(key), Body: string(value), ExpiresAt: expiresAt.UTC()}).Error
+}
+
+// PurgeProviderResponses deletes the persisted responses that expired before the given time
+func PurgeProviderResponses(before time.Time) (int64, error) {
+	result := DB.Where("expires_at < ?", before).Delete(&ProviderResponse{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,107 @@
+package models
+
+import "strings"
+
+// Watchlist positions are lexicographic ranks built from lowercase base36 digits so
+// they sort the same way under case-insensitive collations. A rank never ends with
+// '0' which guarantees there is always room to insert another rank before it.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// RankBetween returns a rank that sorts strictly between a and b. An empty a means
+// the start of the list and an empty b means the end of the list.
+func RankBetween(a, b string) string {
+	if b != "" && a >= b {
+		// Positions are out of order, fall back to placing the item after a
+		b = ""
+	}
+
+	return midpoint(a, b)
+}
+
+// SpreadRanks returns n evenly spaced ranks in ascending order
+func SpreadRanks(n int) []string {
+	base := int64(len(rankDigits))
+
+	width, capacity := 1, base
+	for capacity < int64(n+1)*base {
+		width++
+		capacity *= base
+	}
+
+	step := capacity / int64(n+1)
+	ranks := make([]string, n)
+
+	for i := range ranks {
+		value := step * int64(i+1)
+		digits := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			digits[j] = rankDigits[value%base]
+			value /= base
+		}
+		ranks[i] = strings.TrimRight(string(digits), "0")
+	}
+
+	return ranks
+}
+
+func rankIndex(digit byte) int {
+	return strings.IndexByte(rankDigits, digit)
+}
+
+func rankDigitAt(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+	return rankDigits[0]
+}
+
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the common prefix and find the midpoint of what follows it
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	if a == "" && b == "" {
+		return string(rankDigits[len(rankDigits)/2])
+	}
+
+	// Appending to the end of the list steps by one digit so repeated adds stay short
+	if b == "" {
+		lo := rankIndex(a[0])
+		if lo+1 < len(rankDigits) {
+			return string(rankDigits[lo+1])
+		}
+		return a[:1] + midpoint(a[1:], "")
+	}
+
+	// Likewise moving to the top of the list steps down by one digit
+	hi := rankIndex(b[0])
+	if a == "" {
+		if hi > 1 {
+			return string(rankDigits[hi-1])
+		}
+		if len(b) > 1 {
+			return b[:1]
+		}
+		return rankDigits[:1] + midpoint("", "")
+	}
+
+	lo := rankIndex(a[0])
+	if hi-lo > 1 {
+		return string(rankDigits[(lo+hi)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return a[:1] + midpoint(a[1:], "")
+}
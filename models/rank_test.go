@@ -0,0 +1,133 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkBetween fails unless rank sorts strictly between a and b, where empty means the list ends
+func checkBetween(t *testing.T, a string, b string, rank string) {
+	t.Helper()
+
+	if rank == "" || strings.HasSuffix(rank, "0") || strings.Trim(rank, rankDigits) != "" {
+		t.Fatalf("%q is not a rank", rank)
+	}
+	if rank <= a || (b != "" && rank >= b) {
+		t.Fatalf("%q does not sort strictly between %q and %q", rank, a, b)
+	}
+}
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{"empty list", "", "", "i"},
+		{"bottom", "i", "", "j"},
+		{"bottom after the last digit", "z", "", "zi"},
+		{"bottom after a long rank", "zz5", "", "zz6"},
+		{"top", "", "i", "h"},
+		{"top before the second digit", "", "1", "0i"},
+		{"top before a long rank", "", "1a", "1"},
+		{"between", "a", "k", "f"},
+		{"between adjacent digits", "a", "b", "ai"},
+		{"between adjacent ranks", "a", "a1", "a0i"},
+		{"between ranks sharing a prefix", "ab", "ac", "abi"},
+		{"between a rank and its extension", "a", "ab", "aa"},
+		{"out of order", "b", "a", "c"},
+		{"equal", "b", "b", "c"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := RankBetween(test.a, test.b)
+			if got != test.want {
+				t.Fatalf("RankBetween(%q, %q) = %q, want %q", test.a, test.b, got, test.want)
+			}
+
+			// Neighbours out of order only bound the rank from below
+			b := test.b
+			if b <= test.a {
+				b = ""
+			}
+			checkBetween(t, test.a, b, got)
+		})
+	}
+}
+
+// Inserting again and again next to the same neighbour grows the ranks until the watchlist has
+// to be renumbered
+func TestRankBetweenRunsOutOfRoom(t *testing.T) {
+	tests := []struct {
+		name       string
+		a          string
+		b          string
+		keepsLower bool
+	}{
+		{"after the first", "a", "b", true},
+		{"before the last", "a", "b", false},
+		{"top", "", "i", true},
+		{"bottom", "i", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := test.a, test.b
+			for inserts := 1; ; inserts++ {
+				rank := RankBetween(a, b)
+				checkBetween(t, a, b, rank)
+
+				if len(rank) > MaxPositionLength {
+					// Renumbering is expensive so it must not be needed after a few inserts
+					if inserts < MaxPositionLength {
+						t.Fatalf("ranks outgrew %d characters after only %d inserts", MaxPositionLength, inserts)
+					}
+					return
+				}
+				if inserts == 100000 {
+					t.Fatalf("ranks stay within %d characters after %d inserts", MaxPositionLength, inserts)
+				}
+
+				if test.keepsLower {
+					b = rank
+				} else {
+					a = rank
+				}
+			}
+		})
+	}
+}
+
+func TestSpreadRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 37, 1000, 100000} {
+		ranks := SpreadRanks(n)
+		if len(ranks) != n {
+			t.Fatalf("SpreadRanks(%d) returned %d ranks", n, len(ranks))
+		}
+
+		previous := ""
+		for i, rank := range ranks {
+			checkBetween(t, previous, "", rank)
+			// Renumbered ranks stay far from the length that triggers the next renumbering
+			if len(rank) > MaxPositionLength/8 {
+				t.Fatalf("SpreadRanks(%d)[%d] = %q, want a short rank", n, i, rank)
+			}
+			previous = rank
+		}
+
+		// The spread leaves room to insert at both ends and between every pair
+		if n > 0 {
+			checkBetween(t, "", ranks[0], RankBetween("", ranks[0]))
+			checkBetween(t, ranks[n-1], "", RankBetween(ranks[n-1], ""))
+		}
+		for i := 1; i < n; i++ {
+			rank := RankBetween(ranks[i-1], ranks[i])
+			checkBetween(t, ranks[i-1], ranks[i], rank)
+			if len(rank) > MaxPositionLength {
+				t.Fatalf("inserting between %q and %q needs %d characters", ranks[i-1], ranks[i], len(rank))
+			}
+		}
+	}
+}
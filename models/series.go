@@ -0,0 +1,538 @@
+package models
+
+import (
+	"errors"
+	"movies-backend/providers"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrSeriesNotOwned       = errors.New("series does not belong to user")
+	ErrSeriesAlreadyTracked = errors.New("series is already tracked")
+	ErrEpisodeNotInSeries   = errors.New("episode does not belong to series")
+)
+
+// Statuses reported by TMDb for series that will not air new episodes
+var finishedSeriesStatuses = []string{"Ended", "Canceled"}
+
+// Series is a TV series shared by every user tracking it. Its ID is the TMDb ID.
+type Series struct {
+	ID               uint      `gorm:"primary_key;auto_increment:false" json:"id"`
+	Name             string    `json:"name"`
+	OriginalName     string    `json:"original_name"`
+	Overview         string    `gorm:"type:text" json:"overview"`
+	Image            string    `json:"image"`
+	FirstAirDate     *Date     `gorm:"type:date" json:"first_air_date"`
+	Status           string    `json:"status"`
+	NumberOfSeasons  int       `json:"number_of_seasons"`
+	NumberOfEpisodes int       `json:"number_of_episodes"`
+	RefreshedAt      time.Time `json:"refreshed_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (Series) TableName() string {
+	return "series"
+}
+
+// Episode of a series. Season 0 holds the specials and is left out of progress and notifications.
+type Episode struct {
+	ID            uint       `gorm:"primary_key;auto_increment:false" json:"id"`
+	SeriesID      uint       `gorm:"index" json:"series_id"`
+	SeasonNumber  int        `gorm:"index" json:"season_number"`
+	EpisodeNumber int        `json:"episode_number"`
+	Name          string     `json:"name"`
+	Overview      string     `gorm:"type:text" json:"overview"`
+	AirDate       *Date      `gorm:"type:date" json:"air_date"`
+	Runtime       int        `json:"runtime"`
+	Watched       bool       `gorm:"-" json:"watched"`
+	WatchedAt     *time.Time `gorm:"-" json:"watched_at,omitempty"`
+}
+
+func (Episode) TableName() string {
+	return "episodes"
+}
+
+// UserSeries is a series tracked by a user
+type UserSeries struct {
+	ID       uint    `gorm:"primary_key" json:"id"`
+	UserID   uint    `gorm:"index" json:"user_id"`
+	SeriesID uint    `gorm:"index" json:"series_id"`
+	Series   *Series `gorm:"foreignkey:SeriesID;save_associations:false" json:"series"`
+	// Day the user was last notified about new episodes. Episodes aired before were all notified,
+	// those aired on that day are recorded as NotifiedEpisode.
+	NotifiedUntil   *Date     `gorm:"type:date" json:"-"`
+	WatchedEpisodes int       `gorm:"-" json:"watched_episodes"`
+	AiredEpisodes   int       `gorm:"-" json:"aired_episodes"`
+	NextEpisode     *Episode  `gorm:"-" json:"next_episode"`
+	Episodes        []Episode `gorm:"-" json:"episodes,omitempty"`
+	NewEpisodes     []Episode `gorm:"-" json:"-"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (UserSeries) TableName() string {
+	return "user_series"
+}
+
+type WatchedEpisode struct {
+	ID        uint `gorm:"primary_key"`
+	UserID    uint `gorm:"unique_index:idx_watched_episode"`
+	EpisodeID uint `gorm:"unique_index:idx_watched_episode"`
+	SeriesID  uint `gorm:"index"`
+	WatchedAt time.Time
+}
+
+func (WatchedEpisode) TableName() string {
+	return "watched_episodes"
+}
+
+// NotifiedEpisode is an episode aired on the day the user was last notified about the series
+type NotifiedEpisode struct {
+	ID        uint `gorm:"primary_key"`
+	UserID    uint `gorm:"unique_index:idx_notified_episode"`
+	EpisodeID uint `gorm:"unique_index:idx_notified_episode"`
+	SeriesID  uint `gorm:"index"`
+	AirDate   Date `gorm:"type:date"`
+}
+
+func (NotifiedEpisode) TableName() string {
+	return "notified_episodes"
+}
+
+// parseAirDate returns nil for the empty air dates TMDb reports for unscheduled episodes
+func parseAirDate(value string) *Date {
+	date, err := ParseDate(value)
+	if err != nil {
+		return nil
+	}
+
+	return &date
+}
+
+// NewSeries converts the details of the metadata provider, refreshed now
+func NewSeries(tmdbID uint, details *providers.SeriesDetails) Series {
+	return Series{
+		ID:               tmdbID,
+		Name:             details.Name,
+		OriginalName:     details.OriginalName,
+		Overview:         details.Overview,
+		Image:            details.PosterPath,
+		FirstAirDate:     parseAirDate(details.FirstAirDate),
+		Status:           details.Status,
+		NumberOfSeasons:  details.NumberOfSeasons,
+		NumberOfEpisodes: details.NumberOfEpisodes,
+		RefreshedAt:      time.Now().UTC(),
+	}
+}
+
+func NewEpisode(seriesID uint, episode providers.Episode) Episode {
+	return Episode{
+		ID:            episode.ID,
+		SeriesID:      seriesID,
+		SeasonNumber:  episode.SeasonNumber,
+		EpisodeNumber: episode.EpisodeNumber,
+		Name:          episode.Name,
+		Overview:      episode.Overview,
+		AirDate:       parseAirDate(episode.AirDate),
+		Runtime:       episode.Runtime,
+	}
+}
+
+// RefreshSeries fetches the series and its episodes from the metadata provider. Seasons before
+// the last stored one are complete and are not fetched again.
+func RefreshSeries(tmdbID uint) (*Series, error) {
+	options := providers.DetailsOptions{Language: "en-US"}
+
+	details, err := Metadata.SeriesDetails(tmdbID, options)
+	if err != nil {
+		return nil, err
+	}
+
+	series := NewSeries(tmdbID, details)
+
+	var lastSeason struct{ Number int }
+	DB.Model(&Episode{}).Select("MAX(season_number) AS number").Where("series_id = ?", tmdbID).Scan(&lastSeason)
+
+	var episodes []Episode
+	for _, seasonNumber := range details.Seasons {
+		if seasonNumber < lastSeason.Number {
+			continue
+		}
+
+		season, err := Metadata.SeasonDetails(tmdbID, seasonNumber, options)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, episode := range season.Episodes {
+			episodes = append(episodes, NewEpisode(tmdbID, episode))
+		}
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&series).Error; err != nil {
+			return err
+		}
+
+		for i := range episodes {
+			if err := tx.Save(&episodes[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+// EnsureSeries returns the shared series with the given TMDb ID fetching it when nobody tracks it yet
+func EnsureSeries(tmdbID uint) (*Series, error) {
+	var series Series
+
+	err := DB.First(&series, tmdbID).Error
+	if err == nil {
+		return &series, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return RefreshSeries(tmdbID)
+}
+
+// GetSeriesIDsNeedingRefresh returns the tracked series still airing that were refreshed before the given time
+func GetSeriesIDsNeedingRefresh(staleBefore time.Time) ([]uint, error) {
+	var ids []uint
+
+	err := DB.Model(&Series{}).
+		Where("status NOT IN (?) AND refreshed_at < ? AND id IN ?", finishedSeriesStatuses, staleBefore, DB.Model(&UserSeries{}).Select("series_id").SubQuery()).
+		Pluck("id", &ids).Error
+
+	return ids, err
+}
+
+// AddSeriesToLibrary starts tracking a series. Episodes aired before today are not notified.
+func AddSeriesToLibrary(uid uint, tmdbID uint) (*UserSeries, error) {
+	var count int
+	if err := DB.Model(&UserSeries{}).Where("user_id = ? AND series_id = ?", uid, tmdbID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrSeriesAlreadyTracked
+	}
+
+	series, err := EnsureSeries(tmdbID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := NewDate(time.Now().UTC())
+	userSeries := UserSeries{UserID: uid, SeriesID: series.ID, NotifiedUntil: &today}
+
+	if err := DB.Create(&userSeries).Error; err != nil {
+		return nil, err
+	}
+
+	userSeries.Series = series
+	userSeries.FillProgress(time.Now().UTC())
+
+	return &userSeries, nil
+}
+
+func GetSeriesByUserID(uid uint) ([]UserSeries, error) {
+	var userSeries []UserSeries
+
+	if err := DB.Preload("Series").Order("id").Find(&userSeries, "user_id = ?", uid).Error; err != nil {
+		return userSeries, err
+	}
+
+	now := time.Now().UTC()
+	for i := range userSeries {
+		userSeries[i].FillProgress(now)
+	}
+
+	return userSeries, nil
+}
+
+func getUserSeries(id string, uid uint) (*UserSeries, error) {
+	var userSeries UserSeries
+
+	if err := DB.Preload("Series").First(&userSeries, id).Error; err != nil {
+		return nil, err
+	}
+
+	if userSeries.UserID != uid {
+		return nil, ErrSeriesNotOwned
+	}
+
+	return &userSeries, nil
+}
+
+// GetUserSeriesByID returns a tracked series with all its episodes and their watched state
+func GetUserSeriesByID(id string, uid uint) (*UserSeries, error) {
+	userSeries, err := getUserSeries(id, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	episodes, err := userSeries.episodes()
+	if err != nil {
+		return nil, err
+	}
+
+	userSeries.Episodes = episodes
+	userSeries.FillProgress(time.Now().UTC())
+
+	return userSeries, nil
+}
+
+func DeleteSeriesFromLibraryByID(id string, uid uint) error {
+	userSeries, err := getUserSeries(id, uid)
+	if err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND series_id = ?", uid, userSeries.SeriesID).Delete(&WatchedEpisode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND series_id = ?", uid, userSeries.SeriesID).Delete(&NotifiedEpisode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(userSeries).Error
+	})
+}
+
+// episodes returns every episode of the series ordered by season and number with the watched
+// state of the user
+func (userSeries *UserSeries) episodes() ([]Episode, error) {
+	var episodes []Episode
+
+	if err := DB.Order("season_number, episode_number").Find(&episodes, "series_id = ?", userSeries.SeriesID).Error; err != nil {
+		return episodes, err
+	}
+
+	var watched []WatchedEpisode
+	if err := DB.Find(&watched, "user_id = ? AND series_id = ?", userSeries.UserID, userSeries.SeriesID).Error; err != nil {
+		return episodes, err
+	}
+
+	watchedAt := map[uint]time.Time{}
+	for _, episode := range watched {
+		watchedAt[episode.EpisodeID] = episode.WatchedAt
+	}
+
+	for i := range episodes {
+		if at, found := watchedAt[episodes[i].ID]; found {
+			episodes[i].Watched = true
+			episodes[i].WatchedAt = &at
+		}
+	}
+
+	return episodes, nil
+}
+
+// FillProgress counts the aired and watched episodes and finds the next episode to watch, which is
+// the first aired episode after the last one watched. Episodes are loaded unless already set.
+func (userSeries *UserSeries) FillProgress(now time.Time) {
+	episodes := userSeries.Episodes
+	if episodes == nil {
+		var err error
+		if episodes, err = userSeries.episodes(); err != nil {
+			return
+		}
+	}
+
+	today := NewDate(now)
+	userSeries.AiredEpisodes = 0
+	userSeries.WatchedEpisodes = 0
+	userSeries.NextEpisode = nil
+
+	lastWatched := -1
+	for i, episode := range episodes {
+		if episode.SeasonNumber == 0 {
+			continue
+		}
+		if episode.AirDate != nil && !episode.AirDate.After(today.Time) {
+			userSeries.AiredEpisodes++
+		}
+		if episode.Watched {
+			userSeries.WatchedEpisodes++
+			lastWatched = i
+		}
+	}
+
+	for _, episode := range episodes[lastWatched+1:] {
+		if episode.SeasonNumber == 0 || episode.Watched {
+			continue
+		}
+		if episode.AirDate != nil && !episode.AirDate.After(today.Time) {
+			next := episode
+			userSeries.NextEpisode = &next
+		}
+		break
+	}
+}
+
+// GetNextEpisodeByID returns the next episode of a tracked series to watch, nil when the user is up to date
+func GetNextEpisodeByID(id string, uid uint) (*Episode, error) {
+	userSeries, err := getUserSeries(id, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	userSeries.FillProgress(time.Now().UTC())
+
+	return userSeries.NextEpisode, nil
+}
+
+// MarkEpisodeAsWatchedByID sets or clears the watched state of an episode of a tracked series
+func MarkEpisodeAsWatchedByID(id string, uid uint, episodeID string, watched bool) error {
+	userSeries, err := getUserSeries(id, uid)
+	if err != nil {
+		return err
+	}
+
+	var episode Episode
+	if err := DB.First(&episode, episodeID).Error; err != nil {
+		return err
+	}
+
+	if episode.SeriesID != userSeries.SeriesID {
+		return ErrEpisodeNotInSeries
+	}
+
+	if !watched {
+		return DB.Where("user_id = ? AND episode_id = ?", uid, episode.ID).Delete(&WatchedEpisode{}).Error
+	}
+
+	return markEpisodesAsWatched(DB, uid, []Episode{episode})
+}
+
+// MarkSeasonAsWatchedByID marks every aired episode of a season as watched
+func MarkSeasonAsWatchedByID(id string, uid uint, season int) error {
+	userSeries, err := getUserSeries(id, uid)
+	if err != nil {
+		return err
+	}
+
+	var episodes []Episode
+	err = DB.Where("series_id = ? AND season_number = ? AND air_date <= ?", userSeries.SeriesID, season, NewDate(time.Now().UTC())).Find(&episodes).Error
+	if err != nil {
+		return err
+	}
+
+	if len(episodes) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return markEpisodesAsWatched(tx, uid, episodes)
+	})
+}
+
+func markEpisodesAsWatched(db *gorm.DB, uid uint, episodes []Episode) error {
+	now := time.Now().UTC()
+
+	for _, episode := range episodes {
+		var count int
+		if err := db.Model(&WatchedEpisode{}).Where("user_id = ? AND episode_id = ?", uid, episode.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		watched := WatchedEpisode{UserID: uid, EpisodeID: episode.ID, SeriesID: episode.SeriesID, WatchedAt: now}
+		if err := db.Create(&watched).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetSeriesToNotifyByUserID returns the tracked series with aired episodes the user was not notified about yet
+func GetSeriesToNotifyByUserID(uid uint, today time.Time) ([]UserSeries, error) {
+	var tracked []UserSeries
+
+	if err := DB.Preload("Series").Find(&tracked, "user_id = ?", uid).Error; err != nil {
+		return nil, err
+	}
+
+	day := NewDate(today)
+	var toNotify []UserSeries
+	for _, userSeries := range tracked {
+		// Episodes aired on the day of the last notification may have been listed after it
+		query := DB.Where("series_id = ? AND season_number > 0 AND air_date <= ?", userSeries.SeriesID, day)
+		if userSeries.NotifiedUntil != nil {
+			query = query.Where("air_date >= ?", *userSeries.NotifiedUntil)
+		}
+
+		var aired []Episode
+		if err := query.Order("season_number, episode_number").Find(&aired).Error; err != nil {
+			return nil, err
+		}
+
+		var notified []uint
+		if err := DB.Model(&NotifiedEpisode{}).Where("user_id = ? AND series_id = ?", uid, userSeries.SeriesID).Pluck("episode_id", &notified).Error; err != nil {
+			return nil, err
+		}
+
+		known := map[uint]bool{}
+		for _, id := range notified {
+			known[id] = true
+		}
+		for _, episode := range aired {
+			if !known[episode.ID] {
+				userSeries.NewEpisodes = append(userSeries.NewEpisodes, episode)
+			}
+		}
+
+		if len(userSeries.NewEpisodes) > 0 {
+			toNotify = append(toNotify, userSeries)
+		}
+	}
+
+	return toNotify, nil
+}
+
+// MarkSeriesAsNotified records that the user was notified about the new episodes of the series.
+// Only the episodes aired today are kept since the older ones are never listed again.
+func MarkSeriesAsNotified(tracked []UserSeries, today time.Time) error {
+	day := NewDate(today)
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, userSeries := range tracked {
+			for _, episode := range userSeries.NewEpisodes {
+				if episode.AirDate == nil || episode.AirDate.Before(day.Time) {
+					continue
+				}
+
+				notified := NotifiedEpisode{UserID: userSeries.UserID, EpisodeID: episode.ID, SeriesID: episode.SeriesID, AirDate: *episode.AirDate}
+				if err := tx.Where(NotifiedEpisode{UserID: notified.UserID, EpisodeID: notified.EpisodeID}).FirstOrCreate(&notified).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Where("user_id = ? AND series_id = ? AND air_date < ?", userSeries.UserID, userSeries.SeriesID, day).Delete(&NotifiedEpisode{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&UserSeries{}).Where("id = ?", userSeries.ID).UpdateColumn("notified_until", day).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -3,31 +3,67 @@ package models
 import (
 	"fmt"
 	"log"
+	"movies-backend/migrations"
+	"movies-backend/providers"
 	"os"
+	"strconv"
 
 	tmdb "github.com/cyruzin/golang-tmdb"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/joho/godotenv"
 )
 
 var DB *gorm.DB
 var TMDbClient *tmdb.Client
 
-func ConnectDataBase() {
-	var err error
-	if err = godotenv.Load(".env"); err != nil {
-		log.Fatalf("Error loading .env file")
-	}
+// Metadata is the provider movie metadata is fetched from, behind MetadataCache
+var Metadata providers.Provider
+var MetadataCache *providers.Cached
 
-	DbDriver := os.Getenv("DB_DRIVER")
+// dataSourceName builds the DSN of the driver from the DB_* variables. SQLite only uses DB_NAME,
+// the path of the database file.
+func dataSourceName(driver string) (string, error) {
 	DbHost := os.Getenv("DB_HOST")
 	DbUser := os.Getenv("DB_USER")
 	DbPassword := os.Getenv("DB_PASSWORD")
 	DbName := os.Getenv("DB_NAME")
 	DbPort := os.Getenv("DB_PORT")
 
-	DBUrl := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=False&loc=Local", DbUser, DbPassword, DbHost, DbPort, DbName)
+	switch driver {
+	case dialectMySQL:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local", DbUser, DbPassword, DbHost, DbPort, DbName), nil
+	case dialectPostgres:
+		sslMode := os.Getenv("DB_SSLMODE")
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", DbHost, DbPort, DbUser, DbPassword, DbName, sslMode), nil
+	case dialectSQLite:
+		// Writers wait for each other instead of failing with "database is locked"
+		return fmt.Sprintf("%s?_busy_timeout=5000&_journal_mode=WAL", DbName), nil
+	}
+
+	return "", fmt.Errorf("unsupported DB_DRIVER %q, expected mysql, postgres or sqlite3", driver)
+}
+
+// OpenDataBase connects to the configured database without checking its schema
+func OpenDataBase() {
+	if err := godotenv.Load(".env"); err != nil {
+		log.Fatalf("Error loading .env file")
+	}
+
+	DbDriver := os.Getenv("DB_DRIVER")
+	if DbDriver == "sqlite" {
+		DbDriver = dialectSQLite
+	}
+
+	DBUrl, err := dataSourceName(DbDriver)
+	if err != nil {
+		log.Fatal("configuration error:", err)
+	}
 
 	DB, err = gorm.Open(DbDriver, DBUrl)
 
@@ -37,9 +73,19 @@ func ConnectDataBase() {
 	} else {
 		fmt.Println("We are connected to the database ", DbDriver)
 	}
+}
 
-	DB.AutoMigrate(&User{})
-	DB.AutoMigrate(&Movie{})
+// ConnectDataBase connects to the database and refuses to continue while migrations are pending
+func ConnectDataBase() {
+	OpenDataBase()
+
+	pending, err := migrations.Pending(DB)
+	if err != nil {
+		log.Fatal("Schema version error:", err)
+	}
+	if len(pending) > 0 {
+		log.Fatalf("Database schema is not up to date, %d migrations pending. Run \"%s migrate up\" first", len(pending), os.Args[0])
+	}
 
 	// Initialize TMDb API library
 	TMDbClient, err = tmdb.Init(os.Getenv("TMDB_KEY"))
@@ -47,4 +93,18 @@ func ConnectDataBase() {
 		log.Fatal("TMDb connection error:", err)
 	}
 	TMDbClient.SetClientAutoRetry()
+
+	provider, err := providers.FromEnv(TMDbClient)
+	if err != nil {
+		log.Fatal("Metadata provider error:", err)
+	}
+
+	// Cached responses survive restarts when METADATA_CACHE_PERSIST is set
+	var store providers.CacheStore
+	if persist, _ := strconv.ParseBool(os.Getenv("METADATA_CACHE_PERSIST")); persist {
+		store = ProviderResponseStore{}
+	}
+
+	MetadataCache = providers.NewCached(provider, providers.CacheTTLsFromEnv(), providers.CacheEntriesFromEnv(), store)
+	Metadata = MetadataCache
 }
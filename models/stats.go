@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+type StateCounts struct {
+	Total      int `json:"total"`
+	Watchlist  int `json:"watchlist"`
+	Downloaded int `json:"downloaded"`
+	Watched    int `json:"watched"`
+	Rated      int `json:"rated"`
+}
+
+type RatingCount struct {
+	Rating uint `json:"rating"`
+	Count  int  `json:"count"`
+}
+
+type RatingStats struct {
+	Average      float64       `json:"average"`
+	Distribution []RatingCount `json:"distribution"`
+}
+
+type PeriodCount struct {
+	Year  int `json:"year"`
+	Month int `json:"month,omitempty"`
+	Count int `json:"count"`
+}
+
+type GenreCount struct {
+	Genre string `json:"genre"`
+	Count int    `json:"count"`
+}
+
+type DecadeCount struct {
+	Decade int `json:"decade"`
+	Count  int `json:"count"`
+}
+
+type LibraryStats struct {
+	Counts             StateCounts   `json:"counts"`
+	Ratings            RatingStats   `json:"ratings"`
+	WatchedPerMonth    []PeriodCount `json:"watched_per_month"`
+	WatchedPerYear     []PeriodCount `json:"watched_per_year"`
+	Genres             []GenreCount  `json:"genres"`
+	Decades            []DecadeCount `json:"decades"`
+	AverageDaysToWatch *float64      `json:"average_days_to_watch"`
+}
+
+// libraryOf returns a query on the movies of the user library, trash excluded
+func libraryOf(uid uint) *gorm.DB {
+	return DB.Table(Movie{}.TableName()+" AS m").Where("m.user_id = ? AND m.deleted_at IS NULL", uid)
+}
+
+// GetLibraryStatsByUserID computes the statistics with aggregate queries so no movie row is loaded
+func GetLibraryStatsByUserID(uid uint) (LibraryStats, error) {
+	stats := LibraryStats{
+		Ratings:         RatingStats{Distribution: []RatingCount{}},
+		WatchedPerMonth: []PeriodCount{},
+		WatchedPerYear:  []PeriodCount{},
+		Genres:          []GenreCount{},
+		Decades:         []DecadeCount{},
+	}
+
+	err := libraryOf(uid).
+		Select(`COUNT(*),
+			COALESCE(SUM(CASE WHEN m.downloaded THEN 0 ELSE 1 END), 0),
+			COALESCE(SUM(CASE WHEN m.downloaded THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN m.watched THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN m.rating > 0 THEN 1 ELSE 0 END), 0)`).
+		Row().
+		Scan(&stats.Counts.Total, &stats.Counts.Watchlist, &stats.Counts.Downloaded, &stats.Counts.Watched, &stats.Counts.Rated)
+	if err != nil {
+		return stats, err
+	}
+
+	var average sql.NullFloat64
+	if err := libraryOf(uid).Where("m.rating > 0").Select("AVG(m.rating)").Row().Scan(&average); err != nil {
+		return stats, err
+	}
+	stats.Ratings.Average = average.Float64
+
+	rows, err := libraryOf(uid).Where("m.rating > 0").Select("m.rating, COUNT(*)").Group("m.rating").Order("m.rating").Rows()
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var count RatingCount
+		if err := rows.Scan(&count.Rating, &count.Count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.Ratings.Distribution = append(stats.Ratings.Distribution, count)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return stats, err
+	}
+
+	watchedMonth := yearOf("m.watched_at") + ", " + monthOf("m.watched_at")
+	rows, err = libraryOf(uid).Where("m.watched_at IS NOT NULL").
+		Select(watchedMonth + ", COUNT(*)").
+		Group(watchedMonth).
+		Order(watchedMonth).
+		Rows()
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var count PeriodCount
+		if err := rows.Scan(&count.Year, &count.Month, &count.Count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.WatchedPerMonth = append(stats.WatchedPerMonth, count)
+
+		// Months are ordered so the yearly totals can be accumulated on the way
+		if last := len(stats.WatchedPerYear) - 1; last >= 0 && stats.WatchedPerYear[last].Year == count.Year {
+			stats.WatchedPerYear[last].Count += count.Count
+		} else {
+			stats.WatchedPerYear = append(stats.WatchedPerYear, PeriodCount{Year: count.Year, Count: count.Count})
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err = libraryOf(uid).
+		Joins("JOIN movie_genres ON movie_genres.tmdb_id = m.movie_id").
+		Select("movie_genres.name, COUNT(*)").
+		Group("movie_genres.name").
+		Order("COUNT(*) DESC, movie_genres.name").
+		Rows()
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var count GenreCount
+		if err := rows.Scan(&count.Genre, &count.Count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.Genres = append(stats.Genres, count)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err = libraryOf(uid).
+		Joins("JOIN movie_metadata ON movie_metadata.tmdb_id = m.movie_id").
+		Where("movie_metadata.release_year > 0").
+		Select("movie_metadata.release_year - movie_metadata.release_year % 10, COUNT(*)").
+		Group("movie_metadata.release_year - movie_metadata.release_year % 10").
+		Order("movie_metadata.release_year - movie_metadata.release_year % 10").
+		Rows()
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var count DecadeCount
+		if err := rows.Scan(&count.Decade, &count.Count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.Decades = append(stats.Decades, count)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return stats, err
+	}
+
+	// Movies added before timestamps were recorded can look like they were watched before being added
+	var averageSeconds sql.NullFloat64
+	err = libraryOf(uid).
+		Where("m.watched_at IS NOT NULL AND m.watched_at >= m.created_at").
+		Select("AVG(" + secondsBetween("m.created_at", "m.watched_at") + ")").
+		Row().
+		Scan(&averageSeconds)
+	if err != nil {
+		return stats, err
+	}
+	if averageSeconds.Valid {
+		days := averageSeconds.Float64 / (24 * 60 * 60)
+		stats.AverageDaysToWatch = &days
+	}
+
+	return stats, nil
+}
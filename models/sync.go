@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// LibraryChanges lists the entries changed after a sync cursor. Deleted entries are included
+// as tombstones with deleted_at set. When Reset is set the client cursor is too old or unknown
+// and Changes holds the whole library instead, which replaces the client copy.
+type LibraryChanges struct {
+	Cursor  uint64  `json:"cursor"`
+	Reset   bool    `json:"reset"`
+	Changes []Movie `json:"changes"`
+}
+
+func GetLibraryChangesByUserID(uid uint, since uint64) (LibraryChanges, error) {
+	changes := LibraryChanges{Changes: []Movie{}}
+
+	// A single transaction keeps the cursor consistent with the rows read after it
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var u User
+		if err := tx.Select("id, library_version, purged_change_seq").First(&u, uid).Error; err != nil {
+			return err
+		}
+
+		changes.Cursor = u.LibraryVersion
+
+		if since == 0 || since < u.PurgedChangeSeq || since > u.LibraryVersion {
+			changes.Reset = true
+			return withMetadata(withFilm(tx)).Order("change_seq, id").Find(&changes.Changes, "user_id = ?", uid).Error
+		}
+
+		return withMetadata(withFilm(tx.Unscoped())).Order("change_seq, id").Find(&changes.Changes, "user_id = ? AND change_seq > ?", uid, since).Error
+	})
+
+	return changes, err
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"movies-backend/utils/token"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -13,6 +14,16 @@ type User struct {
 	Password  string `gorm:"size:255;not null;" json:"password"`
 	FirstName string `gorm:"size:255;not null;" json:"first_name"`
 	LastName  string `gorm:"size:255;not null;" json:"last_name"`
+	// Incremented on every change of the user library, used for conditional requests
+	LibraryVersion   uint64     `gorm:"not null;default:0" json:"-"`
+	LibraryUpdatedAt *time.Time `json:"-"`
+	// Highest change sequence of the deleted movies purged from the trash
+	PurgedChangeSeq uint64 `gorm:"not null;default:0" json:"-"`
+	// Comma separated countries and release types that make a movie available
+	Regions      string `gorm:"size:255" json:"-"`
+	ReleaseTypes string `gorm:"size:64" json:"-"`
+	// BCP 47 tag metadata is localized to, empty for the default
+	Language string `gorm:"size:16" json:"-"`
 }
 
 func GetUserByID(uid uint) (User, error) {
@@ -54,5 +65,7 @@ func LoginCheck(email string, password string) (string, User, error) {
 		return "", u, err
 	}
 
+	RecordActivity(u.ID, ActivityLogin, nil)
+
 	return jwt, u, nil
 }
@@ -0,0 +1,335 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations the cache keeps apart, each one with its own time to live and entries
+const (
+	CacheSearch   = "search"
+	CachePopular  = "popular"
+	CacheDetails  = "details"
+	CacheReleases = "releases"
+	CacheSeries   = "series"
+)
+
+// Default number of responses of every operation kept in memory
+const DefaultCacheEntries = 1000
+
+// errAborted is returned to the requests that waited for a provider call that panicked
+var errAborted = errors.New("metadata request was aborted")
+
+// CacheStore persists cached responses so they survive restarts
+type CacheStore interface {
+	Load(key string) (value []byte, expiresAt time.Time, found bool, err error)
+	Save(key string, value []byte, expiresAt time.Time) error
+}
+
+// CacheStats counts how the requests of an operation were answered. Coalesced requests waited
+// for an identical request already in flight instead of asking the provider again. Entries is
+// the number of responses kept in memory.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"`
+	Entries   int    `json:"entries"`
+}
+
+// inflight is a provider call other identical requests wait for
+type inflight struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// Cached answers repeated requests to a provider from memory, and from the store when one is
+// given. Responses are kept encoded so callers never share what they are returned. Failures are
+// not cached, an operation with no time to live is not cached at all. Every operation keeps at
+// most maxEntries responses in memory, dropping the least recently used ones.
+type Cached struct {
+	provider   Provider
+	ttls       map[string]time.Duration
+	store      CacheStore
+	maxEntries int
+
+	mu       sync.Mutex
+	memory   map[string]*lru
+	inflight map[string]*inflight
+	stats    map[string]*CacheStats
+}
+
+func NewCached(provider Provider, ttls map[string]time.Duration, maxEntries int, store CacheStore) *Cached {
+	if maxEntries < 1 {
+		maxEntries = DefaultCacheEntries
+	}
+
+	return &Cached{
+		provider:   provider,
+		ttls:       ttls,
+		store:      store,
+		maxEntries: maxEntries,
+		memory:     map[string]*lru{},
+		inflight:   map[string]*inflight{},
+		stats:      map[string]*CacheStats{},
+	}
+}
+
+// Default minutes the responses of every operation are cached
+var defaultCacheMinutes = map[string]int{
+	CacheSearch:   60,
+	CachePopular:  60,
+	CacheDetails:  24 * 60,
+	CacheReleases: 6 * 60,
+	CacheSeries:   6 * 60,
+}
+
+// Minutes the responses of an operation are cached at least, whatever the environment says.
+// Popular movies are served to anonymous clients so they are never fetched for every request.
+var minimumCacheMinutes = map[string]int{
+	CachePopular: 5,
+}
+
+// CacheEntriesFromEnv reads from METADATA_CACHE_ENTRIES how many responses of every operation
+// are kept in memory
+func CacheEntriesFromEnv() int {
+	if entries, err := strconv.Atoi(os.Getenv("METADATA_CACHE_ENTRIES")); err == nil && entries > 0 {
+		return entries
+	}
+	return DefaultCacheEntries
+}
+
+// CacheTTLsFromEnv reads the time to live of every operation from SEARCH_CACHE_MINUTES,
+// POPULAR_CACHE_MINUTES, DETAILS_CACHE_MINUTES, RELEASES_CACHE_MINUTES and SERIES_CACHE_MINUTES.
+// Zero disables the cache of the operation unless it has a minimum.
+func CacheTTLsFromEnv() map[string]time.Duration {
+	ttls := map[string]time.Duration{}
+
+	for operation, minutes := range defaultCacheMinutes {
+		if value, err := strconv.Atoi(os.Getenv(strings.ToUpper(operation) + "_CACHE_MINUTES")); err == nil && value >= 0 {
+			minutes = value
+		}
+		if minutes < minimumCacheMinutes[operation] {
+			minutes = minimumCacheMinutes[operation]
+		}
+		ttls[operation] = time.Duration(minutes) * time.Minute
+	}
+
+	return ttls
+}
+
+func (c *Cached) Name() string {
+	return c.provider.Name()
+}
+
+// Stats returns a copy of the counters of every operation
+func (c *Cached) Stats() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := map[string]CacheStats{}
+	for operation, counters := range c.stats {
+		stats[operation] = *counters
+	}
+	for operation, memory := range c.memory {
+		counters := stats[operation]
+		counters.Entries = memory.Len()
+		stats[operation] = counters
+	}
+	return stats
+}
+
+// memoryOf returns the responses of the operation kept in memory
+func (c *Cached) memoryOf(operation string) *lru {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.memory[operation] == nil {
+		c.memory[operation] = newLRU(c.maxEntries)
+	}
+	return c.memory[operation]
+}
+
+func (c *Cached) count(operation string, fn func(*CacheStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stats[operation] == nil {
+		c.stats[operation] = &CacheStats{}
+	}
+	fn(c.stats[operation])
+}
+
+// get decodes into out the cached response for the key, calling fetch when there is none
+func (c *Cached) get(operation string, key string, fetch func() (interface{}, error), out interface{}) error {
+	ttl := c.ttls[operation]
+	if ttl <= 0 {
+		value, err := fetch()
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(encoded, out)
+	}
+
+	key = operation + ":" + key
+	memory := c.memoryOf(operation)
+
+	if value, found := memory.Get(key); found {
+		c.count(operation, func(stats *CacheStats) { stats.Hits++ })
+		return json.Unmarshal(value, out)
+	}
+
+	c.mu.Lock()
+	if call, found := c.inflight[key]; found {
+		c.mu.Unlock()
+		<-call.done
+		c.count(operation, func(stats *CacheStats) { stats.Coalesced++ })
+		if call.err != nil {
+			return call.err
+		}
+		return json.Unmarshal(call.value, out)
+	}
+	call := &inflight{done: make(chan struct{}), err: errAborted}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	c.resolve(call, key, func() ([]byte, error) {
+		return c.load(operation, key, ttl, memory, fetch)
+	})
+
+	if call.err != nil {
+		return call.err
+	}
+	return json.Unmarshal(call.value, out)
+}
+
+// resolve runs the call and releases the requests waiting for it, even when the provider panics
+func (c *Cached) resolve(call *inflight, key string, load func() ([]byte, error)) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = load()
+}
+
+// load answers from the store or the provider and caches the answer
+func (c *Cached) load(operation string, key string, ttl time.Duration, memory *lru, fetch func() (interface{}, error)) ([]byte, error) {
+	if c.store != nil {
+		value, expiresAt, found, err := c.store.Load(key)
+		if err != nil {
+			log.Println("Error loading cached metadata", key, err)
+		} else if found && time.Until(expiresAt) > 0 {
+			memory.Set(key, value, time.Until(expiresAt))
+			c.count(operation, func(stats *CacheStats) { stats.Hits++ })
+			return value, nil
+		}
+	}
+
+	c.count(operation, func(stats *CacheStats) { stats.Misses++ })
+
+	fetched, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(fetched)
+	if err != nil {
+		return nil, err
+	}
+
+	memory.Set(key, value, ttl)
+
+	if c.store != nil {
+		if err := c.store.Save(key, value, time.Now().Add(ttl)); err != nil {
+			log.Println("Error saving cached metadata", key, err)
+		}
+	}
+
+	return value, nil
+}
+
+func refKey(ref MovieRef) string {
+	return fmt.Sprintf("%d:%s", ref.TMDbID, ref.IMDbID)
+}
+
+func (c *Cached) SearchMovies(query string, options SearchOptions) (*MovieList, error) {
+	key := fmt.Sprintf("%s:%s:%s:%d:%d:%t:%s", languageOrDefault(options.Language), options.Region, pageOrFirst(options.Page), options.Year, options.PrimaryReleaseYear, options.IncludeAdult, query)
+
+	var list *MovieList
+	err := c.get(CacheSearch, key, func() (interface{}, error) {
+		return c.provider.SearchMovies(query, options)
+	}, &list)
+	return list, err
+}
+
+func (c *Cached) PopularMovies(options ListOptions) (*MovieList, error) {
+	key := fmt.Sprintf("%s:%s:%s", languageOrDefault(options.Language), options.Region, pageOrFirst(options.Page))
+
+	var list *MovieList
+	err := c.get(CachePopular, key, func() (interface{}, error) {
+		return c.provider.PopularMovies(options)
+	}, &list)
+	return list, err
+}
+
+func (c *Cached) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	key := refKey(ref) + ":" + languageOrDefault(options.Language)
+
+	var details *MovieDetails
+	err := c.get(CacheDetails, key, func() (interface{}, error) {
+		return c.provider.MovieDetails(ref, options)
+	}, &details)
+	return details, err
+}
+
+func (c *Cached) ReleaseDates(ref MovieRef) ([]Release, error) {
+	var releases []Release
+	err := c.get(CacheReleases, refKey(ref), func() (interface{}, error) {
+		return c.provider.ReleaseDates(ref)
+	}, &releases)
+	return releases, err
+}
+
+func (c *Cached) SearchSeries(query string, options SearchOptions) (*SeriesList, error) {
+	key := fmt.Sprintf("series:%s:%s:%t:%s", languageOrDefault(options.Language), pageOrFirst(options.Page), options.IncludeAdult, query)
+
+	var list *SeriesList
+	err := c.get(CacheSearch, key, func() (interface{}, error) {
+		return c.provider.SearchSeries(query, options)
+	}, &list)
+	return list, err
+}
+
+func (c *Cached) SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error) {
+	key := fmt.Sprintf("%d:%s", id, languageOrDefault(options.Language))
+
+	var details *SeriesDetails
+	err := c.get(CacheSeries, key, func() (interface{}, error) {
+		return c.provider.SeriesDetails(id, options)
+	}, &details)
+	return details, err
+}
+
+func (c *Cached) SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error) {
+	key := fmt.Sprintf("%d:%d:%s", seriesID, season, languageOrDefault(options.Language))
+
+	var details *Season
+	err := c.get(CacheSeries, key, func() (interface{}, error) {
+		return c.provider.SeasonDetails(seriesID, season, options)
+	}, &details)
+	return details, err
+}
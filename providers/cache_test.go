@@ -0,0 +1,242 @@
+package providers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gated holds every movie details call until release is closed, then answers from the fake or
+// panics when panics is set
+type gated struct {
+	*Fake
+	started chan struct{}
+	release chan struct{}
+	panics  bool
+}
+
+func newGated(fake *Fake, panics bool) *gated {
+	return &gated{Fake: fake, started: make(chan struct{}, 100), release: make(chan struct{}), panics: panics}
+}
+
+func (p *gated) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	p.started <- struct{}{}
+	<-p.release
+	if p.panics {
+		panic("provider bug")
+	}
+	return p.Fake.MovieDetails(ref, options)
+}
+
+// memoryStore keeps the persisted responses in a map
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]storedResponse
+}
+
+type storedResponse struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: map[string]storedResponse{}}
+}
+
+func (s *memoryStore) Load(key string) ([]byte, time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, found := s.entries[key]
+	return entry.value, entry.expiresAt, found, nil
+}
+
+func (s *memoryStore) Save(key string, value []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = storedResponse{value: value, expiresAt: expiresAt}
+	return nil
+}
+
+// newMovies returns a provider with the movies given by ID
+func newMovies(ids ...uint) *Fake {
+	fake := NewFake()
+	for _, id := range ids {
+		movie := MovieDetails{}
+		movie.ID = id
+		movie.Title = "Movie"
+		fake.Add(movie)
+	}
+	return fake
+}
+
+var hourTTLs = map[string]time.Duration{CacheDetails: time.Hour, CachePopular: time.Hour}
+
+func details(t *testing.T, cache *Cached, id uint) {
+	t.Helper()
+
+	movie, err := cache.MovieDetails(MovieRef{TMDbID: id}, DetailsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.ID != id {
+		t.Fatalf("asked for movie %d, got %d", id, movie.ID)
+	}
+}
+
+func expectCalls(t *testing.T, fake *Fake, operation string, want int) {
+	t.Helper()
+
+	if got := fake.Calls(operation); got != want {
+		t.Fatalf("provider called %s %d times, want %d", operation, got, want)
+	}
+}
+
+func TestCachedCoalescesIdenticalRequests(t *testing.T) {
+	fake := newMovies(1)
+	provider := newGated(fake, false)
+	cache := NewCached(provider, hourTTLs, 0, nil)
+
+	const requests = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.MovieDetails(MovieRef{TMDbID: 1}, DetailsOptions{})
+			errs <- err
+		}()
+	}
+
+	// Give the other requests time to find the call in flight
+	<-provider.started
+	time.Sleep(50 * time.Millisecond)
+	close(provider.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectCalls(t, fake, "details", 1)
+	stats := cache.Stats()[CacheDetails]
+	if stats.Misses != 1 || stats.Coalesced == 0 || stats.Hits+stats.Coalesced != requests-1 {
+		t.Fatalf("got %+v, want 1 miss and the other requests coalesced", stats)
+	}
+}
+
+func TestCachedReleasesWaitersWhenProviderPanics(t *testing.T) {
+	fake := newMovies(1)
+	provider := newGated(fake, true)
+	cache := NewCached(provider, hourTTLs, 0, nil)
+
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		cache.MovieDetails(MovieRef{TMDbID: 1}, DetailsOptions{})
+	}()
+	<-provider.started
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := cache.MovieDetails(MovieRef{TMDbID: 1}, DetailsOptions{})
+		waiter <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(provider.release)
+
+	if recovered := <-panicked; recovered == nil {
+		t.Fatal("the provider panic was swallowed")
+	}
+
+	select {
+	case err := <-waiter:
+		if !errors.Is(err, errAborted) {
+			t.Fatalf("waiter got %v, want %v", err, errAborted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter is still blocked after the provider panicked")
+	}
+
+	// Nothing is left in flight so the next request asks the provider again
+	provider.panics = false
+	details(t, cache, 1)
+	expectCalls(t, fake, "details", 1)
+}
+
+func TestCachedEvictsLeastRecentlyUsed(t *testing.T) {
+	fake := newMovies(1, 2, 3)
+	cache := NewCached(fake, hourTTLs, 2, nil)
+
+	details(t, cache, 1)
+	details(t, cache, 2)
+	details(t, cache, 1)
+	expectCalls(t, fake, "details", 2)
+
+	// Movie 2 is the least recently used when movie 3 needs room
+	details(t, cache, 3)
+	details(t, cache, 1)
+	expectCalls(t, fake, "details", 3)
+
+	details(t, cache, 2)
+	expectCalls(t, fake, "details", 4)
+
+	if entries := cache.Stats()[CacheDetails].Entries; entries != 2 {
+		t.Fatalf("cache keeps %d responses, want 2", entries)
+	}
+}
+
+func TestCacheTTLsKeepPopularMinimum(t *testing.T) {
+	t.Setenv("POPULAR_CACHE_MINUTES", "0")
+	t.Setenv("SEARCH_CACHE_MINUTES", "0")
+	t.Setenv("DETAILS_CACHE_MINUTES", "30")
+
+	ttls := CacheTTLsFromEnv()
+	if ttls[CachePopular] != 5*time.Minute || ttls[CacheSearch] != 0 || ttls[CacheDetails] != 30*time.Minute {
+		t.Fatalf("got %v", ttls)
+	}
+
+	fake := newMovies(1)
+	cache := NewCached(fake, ttls, 0, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := cache.PopularMovies(ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cache.SearchMovies("movie", SearchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectCalls(t, fake, "popular", 1)
+	expectCalls(t, fake, "search", 2)
+}
+
+func TestCachedReadsStore(t *testing.T) {
+	store := newMemoryStore()
+	details(t, NewCached(newMovies(1), hourTTLs, 0, store), 1)
+
+	// A cache started later answers from the store without asking the provider
+	fake := newMovies(1)
+	cache := NewCached(fake, hourTTLs, 0, store)
+	details(t, cache, 1)
+	details(t, cache, 1)
+	expectCalls(t, fake, "details", 0)
+
+	if stats := cache.Stats()[CacheDetails]; stats.Hits != 2 || stats.Misses != 0 {
+		t.Fatalf("got %+v, want 2 hits", stats)
+	}
+
+	// Expired responses are fetched again
+	for key, entry := range store.entries {
+		entry.expiresAt = time.Now().Add(-time.Minute)
+		store.entries[key] = entry
+	}
+	details(t, NewCached(fake, hourTTLs, 0, store), 1)
+	expectCalls(t, fake, "details", 1)
+}
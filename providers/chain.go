@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+// Chain asks its providers in order and returns the first successful answer. Providers that do
+// not support an operation are skipped, other failures are logged before trying the next one.
+// Library entries are keyed by TMDb ID so lists are only taken from a provider that knows them,
+// and release dates never fall back since the next provider may not know their countries.
+type Chain []Provider
+
+func (chain Chain) Name() string {
+	names := make([]string, len(chain))
+	for i, provider := range chain {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// first calls fn with every provider until one succeeds
+func (chain Chain) first(operation string, fn func(Provider) error) error {
+	err := ErrNotSupported
+
+	for _, provider := range chain {
+		callErr := fn(provider)
+		if callErr == nil {
+			return nil
+		}
+		if errors.Is(callErr, ErrNotSupported) {
+			continue
+		}
+		if !errors.Is(callErr, ErrNotFound) {
+			log.Printf("Metadata provider %s failed to %s: %v", provider.Name(), operation, callErr)
+		}
+		err = callErr
+	}
+
+	return err
+}
+
+// withTMDbIDs drops the results without a TMDb ID and fails when none is left
+func withTMDbIDs(list *MovieList) (*MovieList, error) {
+	if list == nil || len(list.Results) == 0 {
+		return list, nil
+	}
+
+	results := []MovieSummary{}
+	for _, result := range list.Results {
+		if result.ID != 0 {
+			results = append(results, result)
+		}
+	}
+	if len(results) == 0 {
+		return nil, ErrNoTMDbIDs
+	}
+
+	filtered := *list
+	filtered.Results = results
+	return &filtered, nil
+}
+
+func (chain Chain) SearchMovies(query string, options SearchOptions) (*MovieList, error) {
+	var list *MovieList
+	err := chain.first("search movies", func(provider Provider) (err error) {
+		list, err = provider.SearchMovies(query, options)
+		if err != nil {
+			return err
+		}
+		list, err = withTMDbIDs(list)
+		return err
+	})
+	return list, err
+}
+
+func (chain Chain) PopularMovies(options ListOptions) (*MovieList, error) {
+	var list *MovieList
+	err := chain.first("list popular movies", func(provider Provider) (err error) {
+		list, err = provider.PopularMovies(options)
+		if err != nil {
+			return err
+		}
+		list, err = withTMDbIDs(list)
+		return err
+	})
+	return list, err
+}
+
+func (chain Chain) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	var details *MovieDetails
+	err := chain.first("get movie details", func(provider Provider) (err error) {
+		details, err = provider.MovieDetails(ref, options)
+		return err
+	})
+	return details, err
+}
+
+// ReleaseDates asks the first provider supporting them only
+func (chain Chain) ReleaseDates(ref MovieRef) ([]Release, error) {
+	for _, provider := range chain {
+		releases, err := provider.ReleaseDates(ref)
+		if errors.Is(err, ErrNotSupported) {
+			continue
+		}
+		return releases, err
+	}
+
+	return nil, ErrNotSupported
+}
+
+func (chain Chain) SearchSeries(query string, options SearchOptions) (*SeriesList, error) {
+	var list *SeriesList
+	err := chain.first("search series", func(provider Provider) (err error) {
+		list, err = provider.SearchSeries(query, options)
+		return err
+	})
+	return list, err
+}
+
+func (chain Chain) SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error) {
+	var details *SeriesDetails
+	err := chain.first("get series details", func(provider Provider) (err error) {
+		details, err = provider.SeriesDetails(id, options)
+		return err
+	})
+	return details, err
+}
+
+func (chain Chain) SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error) {
+	var details *Season
+	err := chain.first("get season details", func(provider Provider) (err error) {
+		details, err = provider.SeasonDetails(seriesID, season, options)
+		return err
+	})
+	return details, err
+}
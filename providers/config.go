@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tmdb "github.com/cyruzin/golang-tmdb"
+)
+
+const defaultProvider = "tmdb"
+
+// FromEnv builds the provider configured by METADATA_PROVIDER, a comma separated list of
+// providers asked in order, and METADATA_ENRICHMENT, a provider filling the details the
+// others miss. OMDb needs OMDB_KEY.
+func FromEnv(client *tmdb.Client) (Provider, error) {
+	names := os.Getenv("METADATA_PROVIDER")
+	if names == "" {
+		names = defaultProvider
+	}
+
+	var chain Chain
+	for _, name := range strings.Split(names, ",") {
+		provider, err := byName(strings.TrimSpace(name), client)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, provider)
+	}
+
+	var provider Provider = chain
+	if len(chain) == 1 {
+		provider = chain[0]
+	}
+
+	if name := strings.TrimSpace(os.Getenv("METADATA_ENRICHMENT")); name != "" {
+		secondary, err := byName(name, client)
+		if err != nil {
+			return nil, err
+		}
+		provider = Enriched{Primary: provider, Secondary: secondary}
+	}
+
+	return provider, nil
+}
+
+func byName(name string, client *tmdb.Client) (Provider, error) {
+	switch name {
+	case "tmdb":
+		return NewTMDb(client), nil
+	case "omdb":
+		apiKey := os.Getenv("OMDB_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("metadata provider omdb needs OMDB_KEY")
+		}
+		return NewOMDb(apiKey), nil
+	}
+
+	return nil, fmt.Errorf("unknown metadata provider %q", name)
+}
@@ -0,0 +1,79 @@
+package providers
+
+import "log"
+
+// Enriched answers from its primary provider and fills the details the primary does not know
+// from a secondary one, looked up by the IMDb ID the primary returned
+type Enriched struct {
+	Primary   Provider
+	Secondary Provider
+}
+
+func (p Enriched) Name() string {
+	return p.Primary.Name() + "+" + p.Secondary.Name()
+}
+
+func (p Enriched) SearchMovies(query string, options SearchOptions) (*MovieList, error) {
+	return p.Primary.SearchMovies(query, options)
+}
+
+func (p Enriched) PopularMovies(options ListOptions) (*MovieList, error) {
+	return p.Primary.PopularMovies(options)
+}
+
+func (p Enriched) ReleaseDates(ref MovieRef) ([]Release, error) {
+	return p.Primary.ReleaseDates(ref)
+}
+
+func (p Enriched) SearchSeries(query string, options SearchOptions) (*SeriesList, error) {
+	return p.Primary.SearchSeries(query, options)
+}
+
+func (p Enriched) SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error) {
+	return p.Primary.SeriesDetails(id, options)
+}
+
+func (p Enriched) SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error) {
+	return p.Primary.SeasonDetails(seriesID, season, options)
+}
+
+func (p Enriched) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	details, err := p.Primary.MovieDetails(ref, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if details.IMDbID == "" {
+		return details, nil
+	}
+
+	extra, err := p.Secondary.MovieDetails(MovieRef{TMDbID: details.ID, IMDbID: details.IMDbID}, options)
+	if err != nil {
+		log.Printf("Metadata provider %s failed to enrich %s: %v", p.Secondary.Name(), details.IMDbID, err)
+		return details, nil
+	}
+
+	if details.Overview == "" {
+		details.Overview = extra.Overview
+	}
+	if details.Runtime == 0 {
+		details.Runtime = extra.Runtime
+	}
+	if details.Certification == "" {
+		details.Certification = extra.Certification
+	}
+	if len(details.Genres) == 0 {
+		details.Genres = extra.Genres
+	}
+	if len(details.Cast) == 0 {
+		details.Cast = extra.Cast
+	}
+	if len(details.Directors) == 0 {
+		details.Directors = extra.Directors
+	}
+	if len(details.Releases) == 0 {
+		details.Releases = extra.Releases
+	}
+
+	return details, nil
+}
@@ -0,0 +1,237 @@
+package providers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fake is an in-memory provider for tests. Movies are added with Add, series with AddSeries, and
+// both are searched by title.
+type Fake struct {
+	mu       sync.RWMutex
+	movies   map[uint]MovieDetails
+	releases map[uint][]Release
+	series   map[uint]SeriesDetails
+	seasons  map[uint]map[int]Season
+	calls    map[string]int
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		movies:   map[uint]MovieDetails{},
+		releases: map[uint][]Release{},
+		series:   map[uint]SeriesDetails{},
+		seasons:  map[uint]map[int]Season{},
+		calls:    map[string]int{},
+	}
+}
+
+func (p *Fake) Name() string {
+	return "fake"
+}
+
+// Add stores a movie and its releases, replacing any movie with the same ID
+func (p *Fake) Add(movie MovieDetails, releases ...Release) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.movies[movie.ID] = movie
+	p.releases[movie.ID] = releases
+}
+
+// AddSeries stores a series and its seasons, replacing any series with the same ID
+func (p *Fake) AddSeries(series SeriesDetails, seasons ...Season) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	series.Seasons = []int{}
+	p.seasons[series.ID] = map[int]Season{}
+	for _, season := range seasons {
+		season.SeriesID = series.ID
+		series.Seasons = append(series.Seasons, season.SeasonNumber)
+		p.seasons[series.ID][season.SeasonNumber] = season
+	}
+	sort.Ints(series.Seasons)
+	p.series[series.ID] = series
+}
+
+func (p *Fake) count(operation string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls[operation]++
+}
+
+// Calls returns how many times the operation ("search", "popular", "details", "release_dates",
+// "search_series", "series" or "season") was called
+func (p *Fake) Calls(operation string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.calls[operation]
+}
+
+// sorted returns the stored movies matching the filter by descending popularity
+func (p *Fake) sorted(match func(MovieDetails) bool) []MovieSummary {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	results := []MovieSummary{}
+	for _, movie := range p.movies {
+		if match(movie) {
+			results = append(results, movie.MovieSummary)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Popularity != results[j].Popularity {
+			return results[i].Popularity > results[j].Popularity
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	return results
+}
+
+// page returns one page of twenty results like TMDb does
+func page(results []MovieSummary, number int) *MovieList {
+	const pageSize = 20
+	if number < 1 {
+		number = 1
+	}
+
+	list := &MovieList{
+		Page:         int64(number),
+		TotalResults: int64(len(results)),
+		TotalPages:   int64((len(results) + pageSize - 1) / pageSize),
+		Results:      []MovieSummary{},
+	}
+
+	start := (number - 1) * pageSize
+	if start < len(results) {
+		end := start + pageSize
+		if end > len(results) {
+			end = len(results)
+		}
+		list.Results = results[start:end]
+	}
+
+	return list
+}
+
+func (p *Fake) SearchMovies(query string, options SearchOptions) (*MovieList, error) {
+	p.count("search")
+
+	query = strings.ToLower(query)
+	results := p.sorted(func(movie MovieDetails) bool {
+		title := strings.Contains(strings.ToLower(movie.Title), query) || strings.Contains(strings.ToLower(movie.OriginalTitle), query)
+		year := options.Year == 0 || strings.HasPrefix(movie.ReleaseDate, strconv.Itoa(options.Year))
+		year = year && (options.PrimaryReleaseYear == 0 || strings.HasPrefix(movie.ReleaseDate, strconv.Itoa(options.PrimaryReleaseYear)))
+		return title && year && (options.IncludeAdult || !movie.Adult)
+	})
+
+	return page(results, options.Page), nil
+}
+
+func (p *Fake) PopularMovies(options ListOptions) (*MovieList, error) {
+	p.count("popular")
+
+	return page(p.sorted(func(MovieDetails) bool { return true }), options.Page), nil
+}
+
+// find returns the stored movie matching either ID of the reference
+func (p *Fake) find(ref MovieRef) (MovieDetails, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if movie, found := p.movies[ref.TMDbID]; found && ref.TMDbID != 0 {
+		return movie, true
+	}
+
+	for _, movie := range p.movies {
+		if ref.IMDbID != "" && movie.IMDbID == ref.IMDbID {
+			return movie, true
+		}
+	}
+
+	return MovieDetails{}, false
+}
+
+func (p *Fake) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	p.count("details")
+
+	movie, found := p.find(ref)
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	p.mu.RLock()
+	movie.Releases = append([]Release{}, p.releases[movie.ID]...)
+	p.mu.RUnlock()
+
+	return &movie, nil
+}
+
+func (p *Fake) ReleaseDates(ref MovieRef) ([]Release, error) {
+	p.count("release_dates")
+
+	movie, found := p.find(ref)
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]Release{}, p.releases[movie.ID]...), nil
+}
+
+func (p *Fake) SearchSeries(query string, options SearchOptions) (*SeriesList, error) {
+	p.count("search_series")
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	results := []SeriesSummary{}
+	for _, series := range p.series {
+		if strings.Contains(strings.ToLower(series.Name), query) || strings.Contains(strings.ToLower(series.OriginalName), query) {
+			results = append(results, series.SeriesSummary)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+
+	return &SeriesList{Page: 1, TotalPages: 1, TotalResults: int64(len(results)), Results: results}, nil
+}
+
+func (p *Fake) SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error) {
+	p.count("series")
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	series, found := p.series[id]
+	if !found {
+		return nil, ErrNotFound
+	}
+	series.Seasons = append([]int{}, series.Seasons...)
+
+	return &series, nil
+}
+
+func (p *Fake) SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error) {
+	p.count("season")
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	found, ok := p.seasons[seriesID][season]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found.Episodes = append([]Episode{}, found.Episodes...)
+
+	return &found, nil
+}
@@ -0,0 +1,267 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const omdbBaseURL = "https://www.omdbapi.com/"
+
+// Number of results OMDb returns per search page
+const omdbPageSize = 10
+
+// Value OMDb uses for every unknown field
+const omdbUnknown = "N/A"
+
+// OMDb fetches metadata from the Open Movie Database. It only knows IMDb IDs and has no popular
+// list, so it is meant as a fallback or to enrich another provider.
+type OMDb struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func NewOMDb(apiKey string) *OMDb {
+	return &OMDb{apiKey: apiKey, baseURL: omdbBaseURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *OMDb) Name() string {
+	return "omdb"
+}
+
+type omdbResponse struct {
+	Response string `json:"Response"`
+	Error    string `json:"Error"`
+}
+
+type omdbSearchResponse struct {
+	omdbResponse
+	Search []struct {
+		Title  string `json:"Title"`
+		Year   string `json:"Year"`
+		IMDbID string `json:"imdbID"`
+		Poster string `json:"Poster"`
+	} `json:"Search"`
+	TotalResults string `json:"totalResults"`
+}
+
+type omdbMovie struct {
+	omdbResponse
+	Title      string `json:"Title"`
+	Year       string `json:"Year"`
+	Rated      string `json:"Rated"`
+	Released   string `json:"Released"`
+	Runtime    string `json:"Runtime"`
+	Genre      string `json:"Genre"`
+	Director   string `json:"Director"`
+	Actors     string `json:"Actors"`
+	Plot       string `json:"Plot"`
+	Poster     string `json:"Poster"`
+	IMDbRating string `json:"imdbRating"`
+	IMDbVotes  string `json:"imdbVotes"`
+	IMDbID     string `json:"imdbID"`
+	DVD        string `json:"DVD"`
+}
+
+func (p *OMDb) get(params url.Values, result interface{}) error {
+	params.Set("apikey", p.apiKey)
+
+	resp, err := p.client.Get(p.baseURL + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("omdb returned status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// check turns the error OMDb reports in the body of successful responses into an error
+func (response omdbResponse) check() error {
+	if response.Response == "True" {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(response.Error), "not found") {
+		return ErrNotFound
+	}
+	return errors.New("omdb: " + response.Error)
+}
+
+// known returns an empty string for the fields OMDb does not know
+func known(value string) string {
+	if value == omdbUnknown {
+		return ""
+	}
+	return value
+}
+
+// omdbDate converts the "02 Jan 2006" dates of OMDb
+func omdbDate(value string) (time.Time, bool) {
+	date, err := time.Parse("02 Jan 2006", value)
+	return date, err == nil
+}
+
+func (p *OMDb) SearchMovies(query string, options SearchOptions) (*MovieList, error) {
+	params := url.Values{"s": {query}, "type": {"movie"}, "page": {pageOrFirst(options.Page)}}
+	// OMDb only knows the year a movie was first released
+	if options.PrimaryReleaseYear > 0 {
+		params.Set("y", strconv.Itoa(options.PrimaryReleaseYear))
+	} else if options.Year > 0 {
+		params.Set("y", strconv.Itoa(options.Year))
+	}
+
+	var response omdbSearchResponse
+	if err := p.get(params, &response); err != nil {
+		return nil, err
+	}
+
+	page, _ := strconv.ParseInt(pageOrFirst(options.Page), 10, 64)
+	list := &MovieList{Page: page, Results: []MovieSummary{}}
+
+	if err := response.check(); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return list, nil
+		}
+		return nil, err
+	}
+
+	list.TotalResults, _ = strconv.ParseInt(response.TotalResults, 10, 64)
+	list.TotalPages = (list.TotalResults + omdbPageSize - 1) / omdbPageSize
+
+	for _, result := range response.Search {
+		list.Results = append(list.Results, MovieSummary{
+			IMDbID:        result.IMDbID,
+			Title:         result.Title,
+			OriginalTitle: result.Title,
+			ReleaseDate:   result.Year,
+			PosterPath:    known(result.Poster),
+			GenreIDs:      []int64{},
+		})
+	}
+
+	return list, nil
+}
+
+func (p *OMDb) PopularMovies(options ListOptions) (*MovieList, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *OMDb) movie(ref MovieRef) (*omdbMovie, error) {
+	if ref.IMDbID == "" {
+		return nil, ErrNotSupported
+	}
+
+	var movie omdbMovie
+	if err := p.get(url.Values{"i": {ref.IMDbID}, "plot": {"short"}}, &movie); err != nil {
+		return nil, err
+	}
+
+	if err := movie.check(); err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+func (p *OMDb) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	movie, err := p.movie(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &MovieDetails{
+		MovieSummary: MovieSummary{
+			ID:            ref.TMDbID,
+			IMDbID:        movie.IMDbID,
+			Title:         movie.Title,
+			OriginalTitle: movie.Title,
+			Overview:      known(movie.Plot),
+			ReleaseDate:   movie.Year,
+			PosterPath:    known(movie.Poster),
+			GenreIDs:      []int64{},
+		},
+		Certification: known(movie.Rated),
+		Genres:        []Genre{},
+		Cast:          []CastMember{},
+		Directors:     []CrewMember{},
+		Videos:        []Video{},
+		ExternalIDs:   ExternalIDs{IMDbID: movie.IMDbID},
+		Releases:      omdbReleases(movie),
+	}
+
+	if released, ok := omdbDate(movie.Released); ok {
+		details.ReleaseDate = released.Format("2006-01-02")
+	}
+
+	if rating, err := strconv.ParseFloat(movie.IMDbRating, 32); err == nil {
+		details.VoteAverage = float32(rating)
+	}
+	details.VoteCount, _ = strconv.ParseInt(strings.ReplaceAll(movie.IMDbVotes, ",", ""), 10, 64)
+
+	details.Runtime, _ = strconv.Atoi(strings.TrimSuffix(movie.Runtime, " min"))
+
+	for _, genre := range strings.Split(known(movie.Genre), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			details.Genres = append(details.Genres, Genre{Name: genre})
+		}
+	}
+
+	for _, director := range strings.Split(known(movie.Director), ",") {
+		if director = strings.TrimSpace(director); director != "" {
+			details.Directors = append(details.Directors, CrewMember{Name: director, Job: "Director"})
+		}
+	}
+
+	for _, actor := range strings.Split(known(movie.Actors), ",") {
+		if actor = strings.TrimSpace(actor); actor != "" && len(details.Cast) < maxCastMembers {
+			details.Cast = append(details.Cast, CastMember{Name: actor})
+		}
+	}
+
+	return details, nil
+}
+
+func (p *OMDb) ReleaseDates(ref MovieRef) ([]Release, error) {
+	movie, err := p.movie(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return omdbReleases(movie), nil
+}
+
+// omdbReleases reports the theatrical and DVD releases OMDb knows, without their country
+func omdbReleases(movie *omdbMovie) []Release {
+	releases := []Release{}
+
+	if released, ok := omdbDate(movie.Released); ok {
+		releases = append(releases, Release{Type: ReleaseTheatrical, Date: released, Certification: known(movie.Rated)})
+	}
+	if dvd, ok := omdbDate(movie.DVD); ok {
+		releases = append(releases, Release{Type: ReleasePhysical, Date: dvd})
+	}
+
+	return releases
+}
+
+// Series are only tracked by TMDb ID which OMDb does not know
+func (p *OMDb) SearchSeries(query string, options SearchOptions) (*SeriesList, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *OMDb) SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *OMDb) SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,207 @@
+// Package providers abstracts the services movie metadata is fetched from
+package providers
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("movie not found")
+	ErrNotSupported = errors.New("operation not supported by provider")
+	ErrNoTMDbIDs    = errors.New("results have no TMDb IDs")
+)
+
+// Provider is a source of movie metadata
+type Provider interface {
+	// Name identifies the provider in configuration and logs
+	Name() string
+	SearchMovies(query string, options SearchOptions) (*MovieList, error)
+	PopularMovies(options ListOptions) (*MovieList, error)
+	MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error)
+	ReleaseDates(ref MovieRef) ([]Release, error)
+	SearchSeries(query string, options SearchOptions) (*SeriesList, error)
+	// SeriesDetails and SeasonDetails look series up by TMDb ID
+	SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error)
+	SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error)
+}
+
+// MovieRef identifies a movie by any of the IDs known for it. Providers use the ID they understand
+// and fail with ErrNotSupported when it is missing.
+type MovieRef struct {
+	TMDbID uint
+	IMDbID string
+}
+
+// SearchOptions narrow a search. Year matches any release in that year while
+// PrimaryReleaseYear only matches the first release.
+type SearchOptions struct {
+	Language           string
+	Page               int
+	Year               int
+	PrimaryReleaseYear int
+	Region             string
+	IncludeAdult       bool
+}
+
+type ListOptions struct {
+	Language string
+	Page     int
+	// Region narrows the list to an ISO 3166-1 country, empty means worldwide
+	Region string
+}
+
+type DetailsOptions struct {
+	Language string
+}
+
+// MovieSummary is a movie of a list. Its JSON matches the result items of TMDb so responses
+// keep their shape whatever the provider.
+type MovieSummary struct {
+	ID               uint    `json:"id"`
+	IMDbID           string  `json:"imdb_id,omitempty"`
+	Title            string  `json:"title"`
+	OriginalTitle    string  `json:"original_title"`
+	OriginalLanguage string  `json:"original_language"`
+	Overview         string  `json:"overview"`
+	ReleaseDate      string  `json:"release_date"`
+	PosterPath       string  `json:"poster_path"`
+	BackdropPath     string  `json:"backdrop_path"`
+	GenreIDs         []int64 `json:"genre_ids"`
+	Popularity       float32 `json:"popularity"`
+	VoteAverage      float32 `json:"vote_average"`
+	VoteCount        int64   `json:"vote_count"`
+	Adult            bool    `json:"adult"`
+	Video            bool    `json:"video"`
+}
+
+type MovieList struct {
+	Page         int64          `json:"page"`
+	TotalPages   int64          `json:"total_pages"`
+	TotalResults int64          `json:"total_results"`
+	Results      []MovieSummary `json:"results"`
+}
+
+type Genre struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type CastMember struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Character   string `json:"character"`
+	ProfilePath string `json:"profile_path"`
+}
+
+type CrewMember struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Job         string `json:"job"`
+	ProfilePath string `json:"profile_path"`
+}
+
+// Video is a trailer or teaser hosted on YouTube or Vimeo
+type Video struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Site     string `json:"site"`
+	Type     string `json:"type"`
+	Language string `json:"language"`
+	Official bool   `json:"official"`
+	URL      string `json:"url"`
+}
+
+// ExternalIDs of the movie in other databases and social networks
+type ExternalIDs struct {
+	IMDbID      string `json:"imdb_id"`
+	WikidataID  string `json:"wikidata_id"`
+	FacebookID  string `json:"facebook_id"`
+	InstagramID string `json:"instagram_id"`
+	TwitterID   string `json:"twitter_id"`
+}
+
+type MovieDetails struct {
+	MovieSummary
+	Tagline       string       `json:"tagline"`
+	Status        string       `json:"status"`
+	Homepage      string       `json:"homepage"`
+	Runtime       int          `json:"runtime"`
+	Genres        []Genre      `json:"genres"`
+	Cast          []CastMember `json:"cast"`
+	Directors     []CrewMember `json:"directors"`
+	Videos        []Video      `json:"videos"`
+	ExternalIDs   ExternalIDs  `json:"external_ids"`
+	Certification string       `json:"certification"`
+	Releases      []Release    `json:"releases"`
+}
+
+// Release types as numbered by TMDb
+const (
+	ReleasePremiere          = 1
+	ReleaseTheatricalLimited = 2
+	ReleaseTheatrical        = 3
+	ReleaseDigital           = 4
+	ReleasePhysical          = 5
+	ReleaseTV                = 6
+)
+
+// Release of a movie in one country through one channel. Country is empty when the provider
+// does not know it.
+type Release struct {
+	Country       string    `json:"country"`
+	Type          int       `json:"type"`
+	Date          time.Time `json:"date"`
+	Certification string    `json:"certification"`
+	Note          string    `json:"note"`
+}
+
+// SeriesSummary is a TV series of a list. Its JSON matches the result items of TMDb.
+type SeriesSummary struct {
+	ID               uint     `json:"id"`
+	Name             string   `json:"name"`
+	OriginalName     string   `json:"original_name"`
+	OriginalLanguage string   `json:"original_language"`
+	Overview         string   `json:"overview"`
+	FirstAirDate     string   `json:"first_air_date"`
+	PosterPath       string   `json:"poster_path"`
+	BackdropPath     string   `json:"backdrop_path"`
+	GenreIDs         []int64  `json:"genre_ids"`
+	OriginCountry    []string `json:"origin_country"`
+	Popularity       float32  `json:"popularity"`
+	VoteAverage      float32  `json:"vote_average"`
+	VoteCount        int64    `json:"vote_count"`
+}
+
+type SeriesList struct {
+	Page         int64           `json:"page"`
+	TotalPages   int64           `json:"total_pages"`
+	TotalResults int64           `json:"total_results"`
+	Results      []SeriesSummary `json:"results"`
+}
+
+type SeriesDetails struct {
+	SeriesSummary
+	Status           string `json:"status"`
+	NumberOfSeasons  int    `json:"number_of_seasons"`
+	NumberOfEpisodes int    `json:"number_of_episodes"`
+	// Numbers of the seasons, the specials are season 0
+	Seasons []int `json:"seasons"`
+}
+
+// Episode of a season. AirDate is YYYY-MM-DD, empty while the episode is not scheduled.
+type Episode struct {
+	ID            uint   `json:"id"`
+	SeasonNumber  int    `json:"season_number"`
+	EpisodeNumber int    `json:"episode_number"`
+	Name          string `json:"name"`
+	Overview      string `json:"overview"`
+	AirDate       string `json:"air_date"`
+	Runtime       int    `json:"runtime"`
+}
+
+type Season struct {
+	SeriesID     uint      `json:"series_id"`
+	SeasonNumber int       `json:"season_number"`
+	Episodes     []Episode `json:"episodes"`
+}
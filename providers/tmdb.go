@@ -0,0 +1,408 @@
+package providers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tmdb "github.com/cyruzin/golang-tmdb"
+)
+
+// Number of cast members returned with the details of a movie
+const maxCastMembers = 10
+
+const defaultLanguage = "en-US"
+
+// Status code of TMDb errors for an unknown resource
+const tmdbNotFound = 34
+
+// Where the videos of every supported site are watched, followed by the video key
+var videoURLs = map[string]string{
+	"YouTube": "https://www.youtube.com/watch?v=",
+	"Vimeo":   "https://vimeo.com/",
+}
+
+// TMDb fetches metadata from The Movie Database
+type TMDb struct {
+	client *tmdb.Client
+}
+
+func NewTMDb(client *tmdb.Client) *TMDb {
+	return &TMDb{client: client}
+}
+
+func (p *TMDb) Name() string {
+	return "tmdb"
+}
+
+func languageOrDefault(language string) string {
+	if language == "" {
+		return defaultLanguage
+	}
+	return language
+}
+
+// tmdbError reports the TMDb errors for unknown movies as ErrNotFound
+func tmdbError(err error) error {
+	var tmdbErr tmdb.Error
+	if errors.As(err, &tmdbErr) && tmdbErr.StatusCode == tmdbNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func pageOrFirst(page int) string {
+	if page < 1 {
+		page = 1
+	}
+	return strconv.Itoa(page)
+}
+
+func (p *TMDb) SearchMovies(query string, options SearchOptions) (*MovieList, error) {
+	urlOptions := map[string]string{
+		"language":      languageOrDefault(options.Language),
+		"page":          pageOrFirst(options.Page),
+		"include_adult": strconv.FormatBool(options.IncludeAdult),
+	}
+	if options.Year > 0 {
+		urlOptions["year"] = strconv.Itoa(options.Year)
+	}
+	if options.PrimaryReleaseYear > 0 {
+		urlOptions["primary_release_year"] = strconv.Itoa(options.PrimaryReleaseYear)
+	}
+	if options.Region != "" {
+		urlOptions["region"] = options.Region
+	}
+
+	movies, err := p.client.GetSearchMovies(query, urlOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &MovieList{Page: movies.Page, TotalPages: movies.TotalPages, TotalResults: movies.TotalResults, Results: []MovieSummary{}}
+	if movies.SearchMoviesResults != nil {
+		for _, result := range movies.Results {
+			list.Results = append(list.Results, MovieSummary{
+				ID:               uint(result.ID),
+				Title:            result.Title,
+				OriginalTitle:    result.OriginalTitle,
+				OriginalLanguage: result.OriginalLanguage,
+				Overview:         result.Overview,
+				ReleaseDate:      result.ReleaseDate,
+				PosterPath:       result.PosterPath,
+				BackdropPath:     result.BackdropPath,
+				GenreIDs:         result.GenreIDs,
+				Popularity:       result.Popularity,
+				VoteAverage:      result.VoteAverage,
+				VoteCount:        result.VoteCount,
+				Adult:            result.Adult,
+				Video:            result.Video,
+			})
+		}
+	}
+
+	return list, nil
+}
+
+func (p *TMDb) PopularMovies(options ListOptions) (*MovieList, error) {
+	urlOptions := map[string]string{
+		"language": languageOrDefault(options.Language),
+		"page":     pageOrFirst(options.Page),
+	}
+	if options.Region != "" {
+		urlOptions["region"] = options.Region
+	}
+
+	movies, err := p.client.GetMoviePopular(urlOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &MovieList{Page: movies.Page, TotalPages: movies.TotalPages, TotalResults: movies.TotalResults, Results: []MovieSummary{}}
+	if movies.MoviePopularResults != nil {
+		for _, result := range movies.Results {
+			summary := MovieSummary{
+				ID:               uint(result.ID),
+				Title:            result.Title,
+				OriginalTitle:    result.OriginalTitle,
+				OriginalLanguage: result.OriginalLanguage,
+				Overview:         result.Overview,
+				ReleaseDate:      result.ReleaseDate,
+				PosterPath:       result.PosterPath,
+				BackdropPath:     result.BackdropPath,
+				GenreIDs:         []int64{},
+				Popularity:       result.Popularity,
+				VoteAverage:      result.VoteAverage,
+				VoteCount:        result.VoteCount,
+				Adult:            result.Adult,
+				Video:            result.Video,
+			}
+			for _, genre := range result.Genres {
+				summary.GenreIDs = append(summary.GenreIDs, genre.ID)
+			}
+			list.Results = append(list.Results, summary)
+		}
+	}
+
+	return list, nil
+}
+
+// tmdbID resolves the TMDb ID of the movie looking the IMDb ID up when it is the only one known
+func (p *TMDb) tmdbID(ref MovieRef) (uint, error) {
+	if ref.TMDbID != 0 {
+		return ref.TMDbID, nil
+	}
+	if ref.IMDbID == "" {
+		return 0, ErrNotSupported
+	}
+
+	found, err := p.client.GetFindByID(ref.IMDbID, map[string]string{"external_source": "imdb_id"})
+	if err != nil {
+		return 0, err
+	}
+	if len(found.MovieResults) == 0 {
+		return 0, ErrNotFound
+	}
+
+	return uint(found.MovieResults[0].ID), nil
+}
+
+func (p *TMDb) MovieDetails(ref MovieRef, options DetailsOptions) (*MovieDetails, error) {
+	id, err := p.tmdbID(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	language := languageOrDefault(options.Language)
+	urlOptions := map[string]string{
+		"language":           language,
+		"append_to_response": "credits,release_dates,videos,external_ids",
+		// Trailers are rarely localized, fall back to English and to videos without language
+		"include_video_language": strings.Split(language, "-")[0] + ",en,null",
+	}
+
+	details, err := p.client.GetMovieDetails(int(id), urlOptions)
+	if err != nil {
+		return nil, tmdbError(err)
+	}
+
+	movie := &MovieDetails{
+		MovieSummary: MovieSummary{
+			ID:               uint(details.ID),
+			IMDbID:           details.IMDbID,
+			Title:            details.Title,
+			OriginalTitle:    details.OriginalTitle,
+			OriginalLanguage: details.OriginalLanguage,
+			Overview:         details.Overview,
+			ReleaseDate:      details.ReleaseDate,
+			PosterPath:       details.PosterPath,
+			BackdropPath:     details.BackdropPath,
+			GenreIDs:         []int64{},
+			Popularity:       details.Popularity,
+			VoteAverage:      details.VoteAverage,
+			VoteCount:        details.VoteCount,
+			Adult:            details.Adult,
+			Video:            details.Video,
+		},
+		Tagline:     details.Tagline,
+		Status:      details.Status,
+		Homepage:    details.Homepage,
+		Runtime:     details.Runtime,
+		Genres:      []Genre{},
+		Cast:        []CastMember{},
+		Directors:   []CrewMember{},
+		Videos:      []Video{},
+		ExternalIDs: ExternalIDs{IMDbID: details.IMDbID},
+		Releases:    []Release{},
+	}
+
+	for _, genre := range details.Genres {
+		movie.GenreIDs = append(movie.GenreIDs, genre.ID)
+		movie.Genres = append(movie.Genres, Genre{ID: genre.ID, Name: genre.Name})
+	}
+
+	if details.MovieCreditsAppend != nil && details.Credits.MovieCredits != nil {
+		for _, cast := range details.Credits.Cast {
+			if len(movie.Cast) == maxCastMembers {
+				break
+			}
+			movie.Cast = append(movie.Cast, CastMember{ID: cast.ID, Name: cast.Name, Character: cast.Character, ProfilePath: cast.ProfilePath})
+		}
+		for _, crew := range details.Credits.Crew {
+			if crew.Job == "Director" {
+				movie.Directors = append(movie.Directors, CrewMember{ID: crew.ID, Name: crew.Name, Job: crew.Job, ProfilePath: crew.ProfilePath})
+			}
+		}
+	}
+
+	if details.MovieVideosAppend != nil && details.Videos.MovieVideos != nil && details.Videos.MovieVideosResults != nil {
+		for _, video := range details.Videos.Results {
+			baseURL, supported := videoURLs[video.Site]
+			if !supported || (video.Type != "Trailer" && video.Type != "Teaser") {
+				continue
+			}
+			movie.Videos = append(movie.Videos, Video{
+				Key:      video.Key,
+				Name:     video.Name,
+				Site:     video.Site,
+				Type:     video.Type,
+				Language: video.Iso639_1,
+				Official: video.Official,
+				URL:      baseURL + video.Key,
+			})
+		}
+	}
+
+	if details.MovieExternalIDsAppend != nil && details.MovieExternalIDs != nil {
+		ids := details.MovieExternalIDs
+		movie.ExternalIDs = ExternalIDs{IMDbID: ids.IMDbID, WikidataID: ids.WikiDataID, FacebookID: ids.FacebookID, InstagramID: ids.InstagramID, TwitterID: ids.TwitterID}
+		if movie.ExternalIDs.IMDbID == "" {
+			movie.ExternalIDs.IMDbID = details.IMDbID
+		}
+	}
+
+	if details.MovieReleaseDatesAppend != nil && details.ReleaseDates != nil {
+		movie.Releases = convertReleases(details.ReleaseDates.MovieReleaseDatesResults)
+	}
+
+	return movie, nil
+}
+
+func (p *TMDb) ReleaseDates(ref MovieRef) ([]Release, error) {
+	id, err := p.tmdbID(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	movieInfo, err := p.client.GetMovieReleaseDates(int(id))
+	if err != nil {
+		return nil, tmdbError(err)
+	}
+
+	return convertReleases(movieInfo.MovieReleaseDatesResults), nil
+}
+
+func convertReleases(results *tmdb.MovieReleaseDatesResults) []Release {
+	releases := []Release{}
+	if results == nil {
+		return releases
+	}
+
+	for _, result := range results.Results {
+		for _, releaseDate := range result.ReleaseDates {
+			date, err := time.Parse(time.RFC3339Nano, releaseDate.ReleaseDate)
+			if err != nil {
+				log.Println("Error decoding date", releaseDate.ReleaseDate)
+				continue
+			}
+
+			releases = append(releases, Release{
+				Country:       result.Iso3166_1,
+				Type:          releaseDate.Type,
+				Date:          date,
+				Certification: releaseDate.Certification,
+				Note:          releaseDate.Note,
+			})
+		}
+	}
+
+	return releases
+}
+
+func (p *TMDb) SearchSeries(query string, options SearchOptions) (*SeriesList, error) {
+	urlOptions := map[string]string{
+		"language":      languageOrDefault(options.Language),
+		"page":          pageOrFirst(options.Page),
+		"include_adult": strconv.FormatBool(options.IncludeAdult),
+	}
+
+	shows, err := p.client.GetSearchTVShow(query, urlOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &SeriesList{Page: shows.Page, TotalPages: shows.TotalPages, TotalResults: shows.TotalResults, Results: []SeriesSummary{}}
+	if shows.SearchTVShowsResults != nil {
+		for _, result := range shows.Results {
+			list.Results = append(list.Results, SeriesSummary{
+				ID:               uint(result.ID),
+				Name:             result.Name,
+				OriginalName:     result.OriginalName,
+				OriginalLanguage: result.OriginalLanguage,
+				Overview:         result.Overview,
+				FirstAirDate:     result.FirstAirDate,
+				PosterPath:       result.PosterPath,
+				BackdropPath:     result.BackdropPath,
+				GenreIDs:         result.GenreIDs,
+				OriginCountry:    result.OriginCountry,
+				Popularity:       result.Popularity,
+				VoteAverage:      result.VoteAverage,
+				VoteCount:        result.VoteCount,
+			})
+		}
+	}
+
+	return list, nil
+}
+
+func (p *TMDb) SeriesDetails(id uint, options DetailsOptions) (*SeriesDetails, error) {
+	details, err := p.client.GetTVDetails(int(id), map[string]string{"language": languageOrDefault(options.Language)})
+	if err != nil {
+		return nil, tmdbError(err)
+	}
+
+	series := &SeriesDetails{
+		SeriesSummary: SeriesSummary{
+			ID:               uint(details.ID),
+			Name:             details.Name,
+			OriginalName:     details.OriginalName,
+			OriginalLanguage: details.OriginalLanguage,
+			Overview:         details.Overview,
+			FirstAirDate:     details.FirstAirDate,
+			PosterPath:       details.PosterPath,
+			BackdropPath:     details.BackdropPath,
+			GenreIDs:         []int64{},
+			OriginCountry:    details.OriginCountry,
+			Popularity:       details.Popularity,
+			VoteAverage:      details.VoteAverage,
+			VoteCount:        details.VoteCount,
+		},
+		Status:           details.Status,
+		NumberOfSeasons:  details.NumberOfSeasons,
+		NumberOfEpisodes: details.NumberOfEpisodes,
+		Seasons:          []int{},
+	}
+
+	for _, genre := range details.Genres {
+		series.GenreIDs = append(series.GenreIDs, genre.ID)
+	}
+	for _, season := range details.Seasons {
+		series.Seasons = append(series.Seasons, season.SeasonNumber)
+	}
+
+	return series, nil
+}
+
+func (p *TMDb) SeasonDetails(seriesID uint, season int, options DetailsOptions) (*Season, error) {
+	details, err := p.client.GetTVSeasonDetails(int(seriesID), season, map[string]string{"language": languageOrDefault(options.Language)})
+	if err != nil {
+		return nil, tmdbError(err)
+	}
+
+	result := &Season{SeriesID: seriesID, SeasonNumber: details.SeasonNumber, Episodes: []Episode{}}
+	for _, episode := range details.Episodes {
+		result.Episodes = append(result.Episodes, Episode{
+			ID:            uint(episode.ID),
+			SeasonNumber:  episode.SeasonNumber,
+			EpisodeNumber: episode.EpisodeNumber,
+			Name:          episode.Name,
+			Overview:      episode.Overview,
+			AirDate:       episode.AirDate,
+			Runtime:       episode.Runtime,
+		})
+	}
+
+	return result, nil
+}
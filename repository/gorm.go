@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"movies-backend/models"
+	"movies-backend/providers"
+)
+
+// GormUsers stores the users in the database of the models package
+type GormUsers struct{}
+
+func NewGormUsers() *GormUsers {
+	return &GormUsers{}
+}
+
+func (GormUsers) GetUserByID(uid uint) (models.User, error) {
+	return models.GetUserByID(uid)
+}
+
+func (GormUsers) LoginCheck(email string, password string) (string, models.User, error) {
+	return models.LoginCheck(email, password)
+}
+
+func (GormUsers) GetPreferences(uid uint) (models.Preferences, error) {
+	return models.GetPreferencesByUserID(uid)
+}
+
+func (GormUsers) UpdatePreferences(uid uint, prefs models.PreferencesUpdate) (models.Preferences, error) {
+	return models.UpdatePreferencesByUserID(uid, prefs)
+}
+
+// GormMovies stores the libraries in the database of the models package
+type GormMovies struct{}
+
+func NewGormMovies() *GormMovies {
+	return &GormMovies{}
+}
+
+func (GormMovies) GetWatchlist(uid uint, filter models.LibraryFilter) ([]models.Movie, error) {
+	return models.GetWatchlistByUserID(uid, filter)
+}
+
+func (GormMovies) GetMovies(uid uint, filter models.LibraryFilter) ([]models.Movie, error) {
+	return models.GetMoviesByUserID(uid, filter)
+}
+
+func (GormMovies) AddToWatchlist(movie models.Movie) (*models.Movie, error) {
+	return movie.SaveMovieToWatchlist()
+}
+
+func (GormMovies) ReorderWatchlist(uid uint, moves []models.WatchlistMove) error {
+	return models.ReorderWatchlist(uid, moves)
+}
+
+func (GormMovies) DeleteFromWatchlist(id string, uid uint, version uint) error {
+	return models.DeleteMovieFromWatchlistByID(id, uid, version)
+}
+
+func (GormMovies) MarkAsDownloaded(id string, uid uint, version uint) error {
+	return models.MarkMovieAsDownloadedByID(id, uid, version)
+}
+
+func (GormMovies) MarkAsWatched(id string, uid uint, version uint) error {
+	return models.MarkMovieAsWatchedByID(id, uid, version)
+}
+
+func (GormMovies) Rate(id string, uid uint, rating uint, version uint) error {
+	return models.RateMovieByID(id, uid, rating, version)
+}
+
+func (GormMovies) GetTrash(uid uint) ([]models.Movie, error) {
+	return models.GetTrashByUserID(uid)
+}
+
+func (GormMovies) RestoreFromTrash(id string, uid uint, version uint) (*models.Movie, error) {
+	return models.RestoreMovieFromTrashByID(id, uid, version)
+}
+
+func (GormMovies) LibraryVersion(uid uint) (models.LibraryVersion, error) {
+	return models.GetLibraryVersion(uid)
+}
+
+func (GormMovies) GetEntry(id uint, uid uint) (models.Movie, error) {
+	return models.GetLibraryEntryByID(id, uid)
+}
+
+func (GormMovies) GetEntryByMovieID(uid uint, tmdbID uint) (models.Movie, error) {
+	return models.GetLibraryEntryByMovieID(uid, tmdbID)
+}
+
+func (GormMovies) ForEachMovie(uid uint, batchSize int, fn func([]models.Movie) error) error {
+	return models.ForEachMovieByUserID(uid, batchSize, fn)
+}
+
+func (GormMovies) Import(uid uint, imported models.ImportedMovie) (models.ImportOutcome, error) {
+	return models.ImportMovie(uid, imported)
+}
+
+func (GormMovies) GetChanges(uid uint, since uint64) (models.LibraryChanges, error) {
+	return models.GetLibraryChangesByUserID(uid, since)
+}
+
+func (GormMovies) GetActivity(uid uint, filter models.ActivityFilter) ([]models.Activity, error) {
+	return models.GetActivityByUserID(uid, filter)
+}
+
+func (GormMovies) GetStats(uid uint) (models.LibraryStats, error) {
+	return models.GetLibraryStatsByUserID(uid)
+}
+
+func (GormMovies) Annotate(uid uint, list *providers.MovieList) (*models.AnnotatedMovieList, error) {
+	return models.AnnotateMovieList(uid, list)
+}
+
+// GormSeries stores the tracked series in the database of the models package
+type GormSeries struct{}
+
+func NewGormSeries() *GormSeries {
+	return &GormSeries{}
+}
+
+func (GormSeries) GetSeries(uid uint) ([]models.UserSeries, error) {
+	return models.GetSeriesByUserID(uid)
+}
+
+func (GormSeries) AddSeries(uid uint, tmdbID uint) (*models.UserSeries, error) {
+	return models.AddSeriesToLibrary(uid, tmdbID)
+}
+
+func (GormSeries) GetSeriesByID(id string, uid uint) (*models.UserSeries, error) {
+	return models.GetUserSeriesByID(id, uid)
+}
+
+func (GormSeries) DeleteSeries(id string, uid uint) error {
+	return models.DeleteSeriesFromLibraryByID(id, uid)
+}
+
+func (GormSeries) GetNextEpisode(id string, uid uint) (*models.Episode, error) {
+	return models.GetNextEpisodeByID(id, uid)
+}
+
+func (GormSeries) MarkEpisodeAsWatched(id string, uid uint, episodeID string, watched bool) error {
+	return models.MarkEpisodeAsWatchedByID(id, uid, episodeID, watched)
+}
+
+func (GormSeries) MarkSeasonAsWatched(id string, uid uint, season int) error {
+	return models.MarkSeasonAsWatchedByID(id, uid, season)
+}
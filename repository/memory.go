@@ -0,0 +1,795 @@
+package repository
+
+import (
+	"errors"
+	"movies-backend/models"
+	"movies-backend/providers"
+	"movies-backend/utils/token"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrEmailTaken = errors.New("email is already registered")
+
+// MemoryUsers keeps the users in memory. It is safe for concurrent use.
+type MemoryUsers struct {
+	mu     sync.RWMutex
+	users  map[uint]models.User
+	nextID uint
+}
+
+func NewMemoryUsers() *MemoryUsers {
+	return &MemoryUsers{users: map[uint]models.User{}}
+}
+
+// Add registers a user with the given password and returns it with its new ID
+func (r *MemoryUsers) Add(user models.User, password string) (models.User, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return user, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return user, ErrEmailTaken
+		}
+	}
+
+	r.nextID++
+	user.ID = r.nextID
+	user.Password = string(hashed)
+	r.users[user.ID] = user
+
+	user.PrepareGive()
+	return user, nil
+}
+
+func (r *MemoryUsers) GetUserByID(uid uint) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, found := r.users[uid]
+	if !found {
+		return u, errors.New("user not found")
+	}
+
+	u.PrepareGive()
+	return u, nil
+}
+
+func (r *MemoryUsers) LoginCheck(email string, password string) (string, models.User, error) {
+	r.mu.RLock()
+	var u models.User
+	found := false
+	for _, candidate := range r.users {
+		if candidate.Email == email {
+			u, found = candidate, true
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if !found {
+		return "", models.User{}, gorm.ErrRecordNotFound
+	}
+
+	if err := models.VerifyPassword(password, u.Password); err != nil {
+		return "", u, err
+	}
+
+	jwt, err := token.GenerateToken(u.ID)
+	if err != nil {
+		return "", u, err
+	}
+
+	return jwt, u, nil
+}
+
+func (r *MemoryUsers) GetPreferences(uid uint) (models.Preferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, found := r.users[uid]
+	if !found {
+		return models.Preferences{}, gorm.ErrRecordNotFound
+	}
+
+	return u.Preferences(), nil
+}
+
+func (r *MemoryUsers) UpdatePreferences(uid uint, prefs models.PreferencesUpdate) (models.Preferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, found := r.users[uid]
+	if !found {
+		return models.Preferences{}, gorm.ErrRecordNotFound
+	}
+
+	if err := u.SetPreferences(prefs); err != nil {
+		return models.Preferences{}, err
+	}
+	r.users[uid] = u
+
+	return u.Preferences(), nil
+}
+
+// MemoryMovies keeps the libraries in memory. It is safe for concurrent use. Entries keep the
+// title and image they were added with as there is no shared film to load them from.
+type MemoryMovies struct {
+	mu         sync.RWMutex
+	movies     map[uint]*models.Movie
+	versions   map[uint]models.LibraryVersion
+	activities []models.Activity
+	nextID     uint
+}
+
+func NewMemoryMovies() *MemoryMovies {
+	return &MemoryMovies{movies: map[uint]*models.Movie{}, versions: map[uint]models.LibraryVersion{}}
+}
+
+// record bumps the library version of the user and stamps the changed entries with it
+func (r *MemoryMovies) record(uid uint, changed ...*models.Movie) {
+	now := time.Now().UTC()
+
+	version := r.versions[uid]
+	version.UserID = uid
+	version.Version++
+	version.UpdatedAt = now
+	r.versions[uid] = version
+
+	for _, movie := range changed {
+		movie.Version++
+		movie.ChangeSeq = version.Version
+		movie.UpdatedAt = now
+	}
+}
+
+// log appends to the activity of the user, logins are not recorded in memory
+func (r *MemoryMovies) log(uid uint, action string, movie *models.Movie) {
+	r.activities = append(r.activities, models.Activity{
+		ID:        uint(len(r.activities) + 1),
+		UserID:    uid,
+		Action:    action,
+		EntryID:   movie.ID,
+		MovieID:   movie.MovieID,
+		Title:     movie.Title,
+		Rating:    movie.Rating,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// entry looks an entry up like the database would, the trash is only searched when unscoped is set
+func (r *MemoryMovies) entry(id string, uid uint, unscoped bool) (*models.Movie, error) {
+	key, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	movie, found := r.movies[uint(key)]
+	if !found || (!unscoped && movie.DeletedAt != nil) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if movie.UserID != uid {
+		return nil, models.ErrMovieNotOwned
+	}
+
+	return movie, nil
+}
+
+// atVersion fails like the database would when the client read the entry at another version
+func atVersion(movie *models.Movie, version uint) error {
+	if version != 0 && movie.Version != version {
+		return models.ErrStaleMovie
+	}
+	return nil
+}
+
+func hasGenre(movie *models.Movie, genre string) bool {
+	if genre == "" {
+		return true
+	}
+	if movie.Metadata == nil {
+		return false
+	}
+	for _, g := range movie.Metadata.Genres {
+		if strings.EqualFold(g.Name, genre) {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the entries of the user outside the trash that are in the given state
+func (r *MemoryMovies) list(uid uint, downloaded bool, genre string) []*models.Movie {
+	movies := []*models.Movie{}
+	for _, movie := range r.movies {
+		if movie.UserID == uid && movie.DeletedAt == nil && movie.Downloaded == downloaded && hasGenre(movie, genre) {
+			movies = append(movies, movie)
+		}
+	}
+
+	sort.Slice(movies, func(i, j int) bool {
+		if movies[i].Position != movies[j].Position {
+			return movies[i].Position < movies[j].Position
+		}
+		return movies[i].ID < movies[j].ID
+	})
+
+	return movies
+}
+
+func copies(movies []*models.Movie) []models.Movie {
+	result := make([]models.Movie, len(movies))
+	for i, movie := range movies {
+		result[i] = *movie
+	}
+	return result
+}
+
+func (r *MemoryMovies) GetWatchlist(uid uint, filter models.LibraryFilter) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return copies(r.list(uid, false, filter.Genre)), nil
+}
+
+func (r *MemoryMovies) GetMovies(uid uint, filter models.LibraryFilter) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movies := r.list(uid, true, filter.Genre)
+	sort.Slice(movies, func(i, j int) bool { return movies[i].ID < movies[j].ID })
+
+	return copies(movies), nil
+}
+
+func (r *MemoryMovies) AddToWatchlist(movie models.Movie) (*models.Movie, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added := *r.add(movie)
+	return &added, nil
+}
+
+// add stores a new entry at the end of the watchlist
+func (r *MemoryMovies) add(movie models.Movie) *models.Movie {
+	last := ""
+	if watchlist := r.list(movie.UserID, false, ""); len(watchlist) > 0 {
+		last = watchlist[len(watchlist)-1].Position
+	}
+
+	r.nextID++
+	movie.ID = r.nextID
+	movie.Position = models.RankBetween(last, "")
+	movie.CreatedAt = time.Now().UTC()
+
+	stored := movie
+	r.movies[stored.ID] = &stored
+	r.record(stored.UserID, &stored)
+	r.log(stored.UserID, models.ActivityAdded, &stored)
+
+	return &stored
+}
+
+// watchlistEntry returns an entry the moves of a reorder can refer to
+func (r *MemoryMovies) watchlistEntry(id uint, uid uint) (*models.Movie, error) {
+	movie, err := r.entry(strconv.FormatUint(uint64(id), 10), uid, false)
+	if err != nil {
+		return nil, err
+	}
+	if movie.Downloaded {
+		return nil, models.ErrMovieNotInWatchlist
+	}
+	return movie, nil
+}
+
+// rankAfter follows models.rankAfter: a position right after lower, skipping the moving entry
+func (r *MemoryMovies) rankAfter(uid uint, moving *models.Movie, lower string) string {
+	upper := ""
+	for _, movie := range r.list(uid, false, "") {
+		if movie != moving && movie.Position > lower {
+			upper = movie.Position
+			break
+		}
+	}
+
+	return models.RankBetween(lower, upper)
+}
+
+// renumber spreads the positions of the whole watchlist evenly keeping the current order
+func (r *MemoryMovies) renumber(uid uint) {
+	order := r.list(uid, false, "")
+	for i, rank := range models.SpreadRanks(len(order)) {
+		order[i].Position = rank
+	}
+
+	if len(order) > 0 {
+		r.record(uid, order...)
+	}
+}
+
+// ReorderWatchlist follows models.ReorderWatchlist: only the moved entries are written unless
+// their positions grew too long. Nothing changes when one of the moves is invalid or stale.
+func (r *MemoryMovies) ReorderWatchlist(uid uint, moves []models.WatchlistMove) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, move := range moves {
+		moving, err := r.watchlistEntry(move.ID, uid)
+		if err != nil {
+			return err
+		}
+		if err := atVersion(moving, move.Version); err != nil {
+			return err
+		}
+		if move.AfterID != 0 {
+			if _, err := r.watchlistEntry(move.AfterID, uid); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, move := range moves {
+		moving := r.movies[move.ID]
+
+		lower := ""
+		if move.AfterID != 0 {
+			lower = r.movies[move.AfterID].Position
+		}
+
+		position := r.rankAfter(uid, moving, lower)
+		if len(position) > models.MaxPositionLength {
+			r.renumber(uid)
+			if move.AfterID != 0 {
+				lower = r.movies[move.AfterID].Position
+			}
+			position = r.rankAfter(uid, moving, lower)
+		}
+
+		moving.Position = position
+		r.record(uid, moving)
+	}
+
+	return nil
+}
+
+func (r *MemoryMovies) DeleteFromWatchlist(id string, uid uint, version uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	movie, err := r.entry(id, uid, false)
+	if err != nil {
+		return err
+	}
+
+	if err := atVersion(movie, version); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	movie.DeletedAt = &now
+	r.record(uid, movie)
+	r.log(uid, models.ActivityDeleted, movie)
+
+	return nil
+}
+
+func (r *MemoryMovies) MarkAsDownloaded(id string, uid uint, version uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	movie, err := r.entry(id, uid, false)
+	if err != nil {
+		return err
+	}
+
+	if err := atVersion(movie, version); err != nil {
+		return err
+	}
+
+	movie.Downloaded = true
+	r.record(uid, movie)
+	r.log(uid, models.ActivityDownloaded, movie)
+
+	return nil
+}
+
+func (r *MemoryMovies) MarkAsWatched(id string, uid uint, version uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	movie, err := r.entry(id, uid, false)
+	if err != nil {
+		return err
+	}
+
+	if err := atVersion(movie, version); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	movie.Watched = true
+	movie.WatchedAt = &now
+	r.record(uid, movie)
+	r.log(uid, models.ActivityWatched, movie)
+
+	return nil
+}
+
+func (r *MemoryMovies) Rate(id string, uid uint, rating uint, version uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	movie, err := r.entry(id, uid, false)
+	if err != nil {
+		return err
+	}
+
+	if err := atVersion(movie, version); err != nil {
+		return err
+	}
+
+	movie.Rating = rating
+	r.record(uid, movie)
+	r.log(uid, models.ActivityRated, movie)
+
+	return nil
+}
+
+func (r *MemoryMovies) GetTrash(uid uint) ([]models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	trash := []*models.Movie{}
+	for _, movie := range r.movies {
+		if movie.UserID == uid && movie.DeletedAt != nil {
+			trash = append(trash, movie)
+		}
+	}
+
+	sort.Slice(trash, func(i, j int) bool { return trash[i].DeletedAt.After(*trash[j].DeletedAt) })
+
+	return copies(trash), nil
+}
+
+func (r *MemoryMovies) RestoreFromTrash(id string, uid uint, version uint) (*models.Movie, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	movie, err := r.entry(id, uid, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if movie.DeletedAt == nil {
+		return nil, models.ErrMovieNotInTrash
+	}
+
+	if err := atVersion(movie, version); err != nil {
+		return nil, err
+	}
+
+	movie.DeletedAt = nil
+	r.record(uid, movie)
+	r.log(uid, models.ActivityRestored, movie)
+
+	restored := *movie
+	return &restored, nil
+}
+
+func (r *MemoryMovies) LibraryVersion(uid uint) (models.LibraryVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	version, found := r.versions[uid]
+	if !found {
+		version.UserID = uid
+	}
+
+	return version, nil
+}
+
+func (r *MemoryMovies) GetEntry(id uint, uid uint) (models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movie, err := r.entry(strconv.FormatUint(uint64(id), 10), uid, true)
+	if err != nil {
+		return models.Movie{}, err
+	}
+
+	return *movie, nil
+}
+
+func (r *MemoryMovies) GetEntryByMovieID(uid uint, tmdbID uint) (models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, movie := range r.movies {
+		if movie.UserID == uid && movie.MovieID == tmdbID && movie.DeletedAt == nil {
+			return *movie, nil
+		}
+	}
+
+	return models.Movie{}, gorm.ErrRecordNotFound
+}
+
+// ForEachMovie copies the library before walking it so fn can call back into the repository
+func (r *MemoryMovies) ForEachMovie(uid uint, batchSize int, fn func([]models.Movie) error) error {
+	r.mu.RLock()
+	library := []*models.Movie{}
+	for _, movie := range r.movies {
+		if movie.UserID == uid && movie.DeletedAt == nil {
+			library = append(library, movie)
+		}
+	}
+	sort.Slice(library, func(i, j int) bool { return library[i].ID < library[j].ID })
+	movies := copies(library)
+	r.mu.RUnlock()
+
+	for start := 0; start < len(movies); start += batchSize {
+		end := start + batchSize
+		if end > len(movies) {
+			end = len(movies)
+		}
+		if err := fn(movies[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import follows models.ImportMovie: the entry outside the trash is preferred, otherwise the one
+// trashed last is restored
+func (r *MemoryMovies) Import(uid uint, imported models.ImportedMovie) (models.ImportOutcome, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outcome := models.ImportUnchanged
+
+	var movie *models.Movie
+	for _, candidate := range r.movies {
+		if candidate.UserID != uid || candidate.MovieID != imported.TMDbID {
+			continue
+		}
+		if movie == nil || candidate.DeletedAt == nil || (movie.DeletedAt != nil && candidate.DeletedAt.After(*movie.DeletedAt)) {
+			movie = candidate
+		}
+	}
+
+	if movie == nil {
+		movie = r.add(models.Movie{UserID: uid, MovieID: imported.TMDbID, Title: imported.Title, Image: imported.Image})
+		outcome = models.ImportCreated
+	}
+
+	if movie.DeletedAt != nil {
+		movie.DeletedAt = nil
+		r.record(uid, movie)
+		r.log(uid, models.ActivityRestored, movie)
+		outcome = models.ImportRestored
+	}
+
+	if imported.Watched && !movie.Watched {
+		watchedAt := time.Now().UTC()
+		if imported.WatchedAt != nil {
+			watchedAt = *imported.WatchedAt
+		}
+
+		movie.Downloaded, movie.Watched, movie.WatchedAt = true, true, &watchedAt
+		r.record(uid, movie)
+		r.log(uid, models.ActivityWatched, movie)
+		if outcome == models.ImportUnchanged {
+			outcome = models.ImportUpdated
+		}
+	}
+
+	if imported.Rating > 0 && imported.Rating != movie.Rating {
+		movie.Rating = imported.Rating
+		r.record(uid, movie)
+		r.log(uid, models.ActivityRated, movie)
+		if outcome == models.ImportUnchanged {
+			outcome = models.ImportUpdated
+		}
+	}
+
+	return outcome, nil
+}
+
+// GetChanges never resets a known cursor since nothing is purged from memory
+func (r *MemoryMovies) GetChanges(uid uint, since uint64) (models.LibraryChanges, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := models.LibraryChanges{Cursor: r.versions[uid].Version, Changes: []models.Movie{}}
+	changes.Reset = since == 0 || since > changes.Cursor
+
+	changed := []*models.Movie{}
+	for _, movie := range r.movies {
+		if movie.UserID != uid {
+			continue
+		}
+		if (changes.Reset && movie.DeletedAt == nil) || (!changes.Reset && movie.ChangeSeq > since) {
+			changed = append(changed, movie)
+		}
+	}
+
+	sort.Slice(changed, func(i, j int) bool {
+		if changed[i].ChangeSeq != changed[j].ChangeSeq {
+			return changed[i].ChangeSeq < changed[j].ChangeSeq
+		}
+		return changed[i].ID < changed[j].ID
+	})
+	changes.Changes = copies(changed)
+
+	return changes, nil
+}
+
+func (r *MemoryMovies) GetActivity(uid uint, filter models.ActivityFilter) ([]models.Activity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	activities := []models.Activity{}
+
+	// Activity is appended in order so walking backwards lists the latest first
+	for i := len(r.activities) - 1; i >= 0; i-- {
+		activity := r.activities[i]
+		if activity.UserID != uid || (filter.Action != "" && activity.Action != filter.Action) {
+			continue
+		}
+		if (filter.From != nil && activity.CreatedAt.Before(*filter.From)) || (filter.To != nil && !activity.CreatedAt.Before(*filter.To)) {
+			continue
+		}
+
+		activities = append(activities, activity)
+		if filter.Limit > 0 && len(activities) == filter.Limit {
+			break
+		}
+	}
+
+	return activities, nil
+}
+
+// GetStats computes the same statistics as models.GetLibraryStatsByUserID. Genres and decades
+// only count the entries that carry metadata.
+func (r *MemoryMovies) GetStats(uid uint) (models.LibraryStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := models.LibraryStats{
+		Ratings:         models.RatingStats{Distribution: []models.RatingCount{}},
+		WatchedPerMonth: []models.PeriodCount{},
+		WatchedPerYear:  []models.PeriodCount{},
+		Genres:          []models.GenreCount{},
+		Decades:         []models.DecadeCount{},
+	}
+
+	ratings := map[uint]int{}
+	months := map[models.PeriodCount]int{}
+	genres := map[string]int{}
+	decades := map[int]int{}
+	var ratingSum uint
+	var watchSeconds float64
+	var watchCount int
+
+	for _, movie := range r.movies {
+		if movie.UserID != uid || movie.DeletedAt != nil {
+			continue
+		}
+
+		stats.Counts.Total++
+		if movie.Downloaded {
+			stats.Counts.Downloaded++
+		} else {
+			stats.Counts.Watchlist++
+		}
+		if movie.Watched {
+			stats.Counts.Watched++
+		}
+		if movie.Rating > 0 {
+			stats.Counts.Rated++
+			ratings[movie.Rating]++
+			ratingSum += movie.Rating
+		}
+
+		if movie.WatchedAt != nil {
+			watchedAt := movie.WatchedAt.UTC()
+			months[models.PeriodCount{Year: watchedAt.Year(), Month: int(watchedAt.Month())}]++
+			if !watchedAt.Before(movie.CreatedAt) {
+				watchSeconds += watchedAt.Sub(movie.CreatedAt).Seconds()
+				watchCount++
+			}
+		}
+
+		if movie.Metadata != nil {
+			for _, genre := range movie.Metadata.Genres {
+				genres[genre.Name]++
+			}
+			if year := movie.Metadata.ReleaseYear; year > 0 {
+				decades[year-year%10]++
+			}
+		}
+	}
+
+	if stats.Counts.Rated > 0 {
+		stats.Ratings.Average = float64(ratingSum) / float64(stats.Counts.Rated)
+	}
+	for rating, count := range ratings {
+		stats.Ratings.Distribution = append(stats.Ratings.Distribution, models.RatingCount{Rating: rating, Count: count})
+	}
+	sort.Slice(stats.Ratings.Distribution, func(i, j int) bool {
+		return stats.Ratings.Distribution[i].Rating < stats.Ratings.Distribution[j].Rating
+	})
+
+	for month, count := range months {
+		month.Count = count
+		stats.WatchedPerMonth = append(stats.WatchedPerMonth, month)
+	}
+	sort.Slice(stats.WatchedPerMonth, func(i, j int) bool {
+		a, b := stats.WatchedPerMonth[i], stats.WatchedPerMonth[j]
+		return a.Year < b.Year || (a.Year == b.Year && a.Month < b.Month)
+	})
+	for _, month := range stats.WatchedPerMonth {
+		if last := len(stats.WatchedPerYear) - 1; last >= 0 && stats.WatchedPerYear[last].Year == month.Year {
+			stats.WatchedPerYear[last].Count += month.Count
+		} else {
+			stats.WatchedPerYear = append(stats.WatchedPerYear, models.PeriodCount{Year: month.Year, Count: month.Count})
+		}
+	}
+
+	for genre, count := range genres {
+		stats.Genres = append(stats.Genres, models.GenreCount{Genre: genre, Count: count})
+	}
+	sort.Slice(stats.Genres, func(i, j int) bool {
+		a, b := stats.Genres[i], stats.Genres[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Genre < b.Genre)
+	})
+
+	for decade, count := range decades {
+		stats.Decades = append(stats.Decades, models.DecadeCount{Decade: decade, Count: count})
+	}
+	sort.Slice(stats.Decades, func(i, j int) bool { return stats.Decades[i].Decade < stats.Decades[j].Decade })
+
+	if watchCount > 0 {
+		days := watchSeconds / float64(watchCount) / (24 * 60 * 60)
+		stats.AverageDaysToWatch = &days
+	}
+
+	return stats, nil
+}
+
+func (r *MemoryMovies) Annotate(uid uint, list *providers.MovieList) (*models.AnnotatedMovieList, error) {
+	if list == nil {
+		return nil, nil
+	}
+
+	annotated := models.NewAnnotatedMovieList(list)
+
+	if uid == 0 {
+		return annotated, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movies := []models.Movie{}
+	for _, movie := range r.movies {
+		if movie.UserID == uid && movie.DeletedAt == nil {
+			movies = append(movies, *movie)
+		}
+	}
+	annotated.Annotate(movies)
+
+	return annotated, nil
+}
@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"movies-backend/models"
+	"movies-backend/providers"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type watchedEpisode struct {
+	userID    uint
+	episodeID uint
+}
+
+// MemorySeries keeps the tracked series in memory. Series and their episodes are fetched from
+// the metadata provider when first tracked. It is safe for concurrent use.
+type MemorySeries struct {
+	metadata providers.Provider
+
+	mu       sync.RWMutex
+	series   map[uint]models.Series
+	episodes map[uint][]models.Episode
+	tracked  map[uint]*models.UserSeries
+	watched  map[watchedEpisode]time.Time
+	nextID   uint
+}
+
+func NewMemorySeries(metadata providers.Provider) *MemorySeries {
+	return &MemorySeries{
+		metadata: metadata,
+		series:   map[uint]models.Series{},
+		episodes: map[uint][]models.Episode{},
+		tracked:  map[uint]*models.UserSeries{},
+		watched:  map[watchedEpisode]time.Time{},
+	}
+}
+
+// fetch loads the series and every episode ordered by season and number
+func (r *MemorySeries) fetch(tmdbID uint) (models.Series, []models.Episode, error) {
+	options := providers.DetailsOptions{Language: "en-US"}
+
+	details, err := r.metadata.SeriesDetails(tmdbID, options)
+	if err != nil {
+		return models.Series{}, nil, err
+	}
+
+	episodes := []models.Episode{}
+	for _, seasonNumber := range details.Seasons {
+		season, err := r.metadata.SeasonDetails(tmdbID, seasonNumber, options)
+		if err != nil {
+			return models.Series{}, nil, err
+		}
+
+		for _, episode := range season.Episodes {
+			episodes = append(episodes, models.NewEpisode(tmdbID, episode))
+		}
+	}
+
+	sort.SliceStable(episodes, func(i, j int) bool {
+		if episodes[i].SeasonNumber != episodes[j].SeasonNumber {
+			return episodes[i].SeasonNumber < episodes[j].SeasonNumber
+		}
+		return episodes[i].EpisodeNumber < episodes[j].EpisodeNumber
+	})
+
+	return models.NewSeries(tmdbID, details), episodes, nil
+}
+
+// userSeries looks a tracked series up like the database would
+func (r *MemorySeries) userSeries(id string, uid uint) (*models.UserSeries, error) {
+	key, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	tracked, found := r.tracked[uint(key)]
+	if !found {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if tracked.UserID != uid {
+		return nil, models.ErrSeriesNotOwned
+	}
+
+	return tracked, nil
+}
+
+// progress returns a copy of the tracked series with its progress, and its episodes when asked
+func (r *MemorySeries) progress(tracked *models.UserSeries, withEpisodes bool) models.UserSeries {
+	userSeries := *tracked
+
+	series := r.series[tracked.SeriesID]
+	userSeries.Series = &series
+
+	episodes := make([]models.Episode, len(r.episodes[tracked.SeriesID]))
+	copy(episodes, r.episodes[tracked.SeriesID])
+	for i := range episodes {
+		if at, found := r.watched[watchedEpisode{userID: tracked.UserID, episodeID: episodes[i].ID}]; found {
+			episodes[i].Watched = true
+			episodes[i].WatchedAt = &at
+		}
+	}
+
+	userSeries.Episodes = episodes
+	userSeries.FillProgress(time.Now().UTC())
+	if !withEpisodes {
+		userSeries.Episodes = nil
+	}
+
+	return userSeries
+}
+
+func (r *MemorySeries) GetSeries(uid uint) ([]models.UserSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userSeries := []models.UserSeries{}
+	for _, tracked := range r.tracked {
+		if tracked.UserID == uid {
+			userSeries = append(userSeries, r.progress(tracked, false))
+		}
+	}
+
+	sort.Slice(userSeries, func(i, j int) bool { return userSeries[i].ID < userSeries[j].ID })
+
+	return userSeries, nil
+}
+
+func (r *MemorySeries) isTracked(uid uint, tmdbID uint) bool {
+	for _, tracked := range r.tracked {
+		if tracked.UserID == uid && tracked.SeriesID == tmdbID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemorySeries) AddSeries(uid uint, tmdbID uint) (*models.UserSeries, error) {
+	r.mu.RLock()
+	tracked := r.isTracked(uid, tmdbID)
+	_, known := r.series[tmdbID]
+	r.mu.RUnlock()
+
+	if tracked {
+		return nil, models.ErrSeriesAlreadyTracked
+	}
+
+	// The provider is called without holding the lock
+	var series models.Series
+	var episodes []models.Episode
+	if !known {
+		var err error
+		if series, episodes, err = r.fetch(tmdbID); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isTracked(uid, tmdbID) {
+		return nil, models.ErrSeriesAlreadyTracked
+	}
+	if _, found := r.series[tmdbID]; !found {
+		r.series[tmdbID] = series
+		r.episodes[tmdbID] = episodes
+	}
+
+	now := time.Now().UTC()
+	today := models.NewDate(now)
+
+	r.nextID++
+	r.tracked[r.nextID] = &models.UserSeries{ID: r.nextID, UserID: uid, SeriesID: tmdbID, NotifiedUntil: &today, CreatedAt: now, UpdatedAt: now}
+
+	added := r.progress(r.tracked[r.nextID], false)
+	return &added, nil
+}
+
+func (r *MemorySeries) GetSeriesByID(id string, uid uint) (*models.UserSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tracked, err := r.userSeries(id, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	userSeries := r.progress(tracked, true)
+	return &userSeries, nil
+}
+
+func (r *MemorySeries) DeleteSeries(id string, uid uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tracked, err := r.userSeries(id, uid)
+	if err != nil {
+		return err
+	}
+
+	for _, episode := range r.episodes[tracked.SeriesID] {
+		delete(r.watched, watchedEpisode{userID: uid, episodeID: episode.ID})
+	}
+	delete(r.tracked, tracked.ID)
+
+	return nil
+}
+
+func (r *MemorySeries) GetNextEpisode(id string, uid uint) (*models.Episode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tracked, err := r.userSeries(id, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.progress(tracked, false).NextEpisode, nil
+}
+
+func (r *MemorySeries) MarkEpisodeAsWatched(id string, uid uint, episodeID string, watched bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tracked, err := r.userSeries(id, uid)
+	if err != nil {
+		return err
+	}
+
+	key, err := strconv.ParseUint(episodeID, 10, 64)
+	if err != nil {
+		return gorm.ErrRecordNotFound
+	}
+
+	var episode *models.Episode
+	for seriesID := range r.episodes {
+		for i := range r.episodes[seriesID] {
+			if r.episodes[seriesID][i].ID == uint(key) {
+				episode = &r.episodes[seriesID][i]
+			}
+		}
+	}
+
+	if episode == nil {
+		return gorm.ErrRecordNotFound
+	}
+
+	if episode.SeriesID != tracked.SeriesID {
+		return models.ErrEpisodeNotInSeries
+	}
+
+	watchedKey := watchedEpisode{userID: uid, episodeID: episode.ID}
+	if !watched {
+		delete(r.watched, watchedKey)
+		return nil
+	}
+
+	if _, found := r.watched[watchedKey]; !found {
+		r.watched[watchedKey] = time.Now().UTC()
+	}
+
+	return nil
+}
+
+// MarkSeasonAsWatched marks every aired episode of a season as watched
+func (r *MemorySeries) MarkSeasonAsWatched(id string, uid uint, season int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tracked, err := r.userSeries(id, uid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	today := models.NewDate(now)
+
+	var aired []models.Episode
+	for _, episode := range r.episodes[tracked.SeriesID] {
+		if episode.SeasonNumber == season && episode.AirDate != nil && !episode.AirDate.After(today.Time) {
+			aired = append(aired, episode)
+		}
+	}
+
+	if len(aired) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	for _, episode := range aired {
+		watchedKey := watchedEpisode{userID: uid, episodeID: episode.ID}
+		if _, found := r.watched[watchedKey]; !found {
+			r.watched[watchedKey] = now
+		}
+	}
+
+	return nil
+}
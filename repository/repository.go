@@ -0,0 +1,60 @@
+// Package repository abstracts the storage the HTTP handlers read users, libraries and series
+// from, so the API can run against the database or against memory.
+package repository
+
+import (
+	"movies-backend/models"
+	"movies-backend/providers"
+)
+
+// UserRepository gives access to the user accounts
+type UserRepository interface {
+	GetUserByID(uid uint) (models.User, error)
+	// LoginCheck verifies the credentials and returns a token for the user
+	LoginCheck(email string, password string) (string, models.User, error)
+	GetPreferences(uid uint) (models.Preferences, error)
+	UpdatePreferences(uid uint, prefs models.PreferencesUpdate) (models.Preferences, error)
+}
+
+// MovieRepository gives access to the library of the users. Entries are addressed by the ID
+// received in the request and fail with models.ErrMovieNotOwned when they belong to another user.
+// Writes taking a version fail with models.ErrStaleMovie unless the entry is still at that
+// version, 0 skips the check.
+type MovieRepository interface {
+	GetWatchlist(uid uint, filter models.LibraryFilter) ([]models.Movie, error)
+	GetMovies(uid uint, filter models.LibraryFilter) ([]models.Movie, error)
+	AddToWatchlist(movie models.Movie) (*models.Movie, error)
+	ReorderWatchlist(uid uint, moves []models.WatchlistMove) error
+	DeleteFromWatchlist(id string, uid uint, version uint) error
+	MarkAsDownloaded(id string, uid uint, version uint) error
+	MarkAsWatched(id string, uid uint, version uint) error
+	Rate(id string, uid uint, rating uint, version uint) error
+	GetTrash(uid uint) ([]models.Movie, error)
+	RestoreFromTrash(id string, uid uint, version uint) (*models.Movie, error)
+	LibraryVersion(uid uint) (models.LibraryVersion, error)
+	// GetEntry returns an entry of the user library, trash included
+	GetEntry(id uint, uid uint) (models.Movie, error)
+	// GetEntryByMovieID returns the entry of the user library for a TMDb ID, trash excluded
+	GetEntryByMovieID(uid uint, tmdbID uint) (models.Movie, error)
+	// ForEachMovie walks the user library, trash excluded, in batches ordered by ID
+	ForEachMovie(uid uint, batchSize int, fn func([]models.Movie) error) error
+	Import(uid uint, imported models.ImportedMovie) (models.ImportOutcome, error)
+	GetChanges(uid uint, since uint64) (models.LibraryChanges, error)
+	GetActivity(uid uint, filter models.ActivityFilter) ([]models.Activity, error)
+	GetStats(uid uint) (models.LibraryStats, error)
+	// Annotate adds the library state of every result for the user, 0 for anonymous callers
+	Annotate(uid uint, list *providers.MovieList) (*models.AnnotatedMovieList, error)
+}
+
+// SeriesRepository gives access to the series tracked by the users. Tracked series are addressed
+// by the ID received in the request and fail with models.ErrSeriesNotOwned when they belong to
+// another user.
+type SeriesRepository interface {
+	GetSeries(uid uint) ([]models.UserSeries, error)
+	AddSeries(uid uint, tmdbID uint) (*models.UserSeries, error)
+	GetSeriesByID(id string, uid uint) (*models.UserSeries, error)
+	DeleteSeries(id string, uid uint) error
+	GetNextEpisode(id string, uid uint) (*models.Episode, error)
+	MarkEpisodeAsWatched(id string, uid uint, episodeID string, watched bool) error
+	MarkSeasonAsWatched(id string, uid uint, season int) error
+}
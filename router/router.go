@@ -0,0 +1,78 @@
+// Package router registers the routes of the HTTP API
+package router
+
+import (
+	"movies-backend/controllers"
+	"movies-backend/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// New returns the engine serving the API. Everything but the suggestions, the release date update
+// and the cache statistics is served by the handler, so an in-memory handler makes the API usable
+// without a database.
+func New(h *controllers.Handler) *gin.Engine {
+	r := gin.Default()
+	r.Use(middlewares.CORSMiddleware())
+
+	public := r.Group("/api")
+
+	public.POST("/login", h.Login)
+
+	public.GET("/popular", h.GetPopularMovies)
+
+	public.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"version": "1.0"})
+	})
+
+	private := r.Group("/api")
+
+	private.Use(middlewares.JwtAuthMiddleware())
+	{
+		private.GET("/user", h.CurrentUser)
+		private.GET("/user/preferences", h.GetPreferences)
+		private.PUT("/user/preferences", h.UpdatePreferences)
+		private.GET("/movies/suggestion", controllers.MoviesSuggestion)
+		private.GET("/trash", h.GetTrash)
+		private.GET("/activity", h.GetActivity)
+		private.GET("/stats", h.GetStats)
+		private.POST("/import", h.ImportLibrary)
+		private.GET("/export", h.ExportLibrary)
+		private.GET("/library/search", h.SearchLibrary)
+		private.GET("/sync", h.GetSyncChanges)
+		private.POST("/sync", h.PostSyncMutations)
+		private.GET("/update", controllers.UpdateReleaseDates)
+		private.GET("/metadata/cache", controllers.GetMetadataCacheStats)
+		private.GET("/tmdb/movies/:tmdbId", h.GetMovieDetails)
+		private.POST("/search", h.SearchForMovie)
+		private.POST("/autocomplete", h.AutocompleteSearch)
+		private.POST("/series/search", h.SearchForSeries)
+		private.GET("/series", h.GetSeries)
+		private.POST("/series", h.AddSeries)
+		private.GET("/series/:id", h.GetSeriesDetails)
+		private.DELETE("/series/:id", h.DeleteSeries)
+		private.GET("/series/:id/next", h.GetNextEpisode)
+		private.POST("/series/:id/episodes/:episodeId/watched", h.MarkEpisodeAsWatched)
+		private.DELETE("/series/:id/episodes/:episodeId/watched", h.UnmarkEpisodeAsWatched)
+		private.POST("/series/:id/seasons/:season/watched", h.MarkSeasonAsWatched)
+	}
+
+	// Library endpoints support ETag based conditional requests
+	library := private.Group("")
+
+	library.Use(middlewares.LibraryConditionalMiddleware(h.Movies))
+	{
+		library.GET("/watchlist", h.GetWatchlist)
+		library.GET("/movies", h.GetMovies)
+		library.POST("/watchlist", h.AddToWatchlist)
+		library.POST("/watchlist/reorder", h.ReorderWatchlist)
+		library.POST("/movies/mark/downloaded/:id", h.MarkMovieAsDownloaded)
+		library.POST("/movies/mark/watched/:id", h.MarkMovieAsWatched)
+		library.POST("/movies/rate/:id", h.RateMovie)
+		library.DELETE("/watchlist/:id", h.DeleteFromWatchlist)
+		library.POST("/trash/:id/restore", h.RestoreFromTrash)
+	}
+
+	return r
+}
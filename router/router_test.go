@@ -0,0 +1,476 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"movies-backend/controllers"
+	"movies-backend/migrations"
+	"movies-backend/models"
+	"movies-backend/providers"
+	"movies-backend/repository"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "secret-password"
+
+type testAPI struct {
+	t       *testing.T
+	engine  *gin.Engine
+	addUser func(user models.User, password string) error
+}
+
+// backends lists the storages the suite runs against, every test runs once on each
+var backends = []string{"memory", "gorm"}
+
+func eachBackend(t *testing.T, test func(t *testing.T, api *testAPI)) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			test(t, newTestAPI(t, backend))
+		})
+	}
+}
+
+// newTestAPI serves the API from memory or from a fresh SQLite database migrated to the latest
+// schema. Both use the fake metadata provider.
+func newTestAPI(t *testing.T, backend string) *testAPI {
+	t.Setenv("API_SECRET", "test-secret")
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "1")
+	gin.SetMode(gin.TestMode)
+
+	metadata := providers.NewFake()
+	api := &testAPI{t: t}
+
+	var h *controllers.Handler
+	switch backend {
+	case "memory":
+		users := repository.NewMemoryUsers()
+		api.addUser = func(user models.User, password string) error {
+			_, err := users.Add(user, password)
+			return err
+		}
+		h = controllers.NewHandler(users, repository.NewMemoryMovies(), repository.NewMemorySeries(metadata), metadata)
+
+	case "gorm":
+		db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "movies.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := migrations.Up(db); err != nil {
+			t.Fatal(err)
+		}
+
+		previousDB, previousMetadata := models.DB, models.Metadata
+		models.DB, models.Metadata = db, metadata
+		t.Cleanup(func() {
+			models.DB, models.Metadata = previousDB, previousMetadata
+			db.Close()
+		})
+
+		api.addUser = func(user models.User, password string) error {
+			hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+			if err != nil {
+				return err
+			}
+			user.Password = string(hashed)
+			return db.Create(&user).Error
+		}
+		h = controllers.NewHandler(repository.NewGormUsers(), repository.NewGormMovies(), repository.NewGormSeries(), metadata)
+
+	default:
+		t.Fatalf("unknown backend %q", backend)
+	}
+
+	api.engine = New(h)
+	return api
+}
+
+// serve sends the request with the token and headers given, body is encoded as JSON
+func (api *testAPI) serve(method string, path string, token string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			api.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	api.engine.ServeHTTP(w, req)
+	return w
+}
+
+func (api *testAPI) login(email string, password string) *httptest.ResponseRecorder {
+	form := url.Values{"email": {email}, "password": {password}}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	api.engine.ServeHTTP(w, req)
+	return w
+}
+
+// register adds a user and returns a token to act as that user
+func (api *testAPI) register(email string) string {
+	api.t.Helper()
+
+	if err := api.addUser(models.User{Email: email}, testPassword); err != nil {
+		api.t.Fatal(err)
+	}
+
+	w := api.login(email, testPassword)
+	if w.Code != http.StatusOK {
+		api.t.Fatalf("login as %s: %d %s", email, w.Code, w.Body)
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	decode(api.t, w, &response)
+	return response.Token
+}
+
+func (api *testAPI) add(token string, tmdbID uint) models.Movie {
+	api.t.Helper()
+
+	w := api.serve(http.MethodPost, "/api/watchlist", token, gin.H{"title": fmt.Sprint("Movie ", tmdbID), "movie_id": tmdbID})
+	expectStatus(api.t, w, http.StatusCreated)
+
+	var movie models.Movie
+	decode(api.t, w, &movie)
+	return movie
+}
+
+// entries returns the watchlist entries in order
+func (api *testAPI) entries(token string) []models.Movie {
+	api.t.Helper()
+
+	w := api.serve(http.MethodGet, "/api/watchlist", token, nil)
+	expectStatus(api.t, w, http.StatusOK)
+
+	var movies []models.Movie
+	decode(api.t, w, &movies)
+	return movies
+}
+
+// watchlist returns the IDs of the watchlist entries in order
+func (api *testAPI) watchlist(token string) []uint {
+	api.t.Helper()
+
+	ids := []uint{}
+	for _, movie := range api.entries(token) {
+		ids = append(ids, movie.ID)
+	}
+	return ids
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("got status %d, want %d: %s", w.Code, status, w.Body)
+	}
+}
+
+func expectIDs(t *testing.T, got []uint, want ...uint) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got entries %v, want %v", got, want)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		api.register("alice@example.com")
+
+		expectStatus(t, api.login("alice@example.com", "wrong"), http.StatusBadRequest)
+		expectStatus(t, api.login("bob@example.com", testPassword), http.StatusBadRequest)
+	})
+}
+
+func TestPrivateRoutesNeedToken(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		expectStatus(t, api.serve(http.MethodGet, "/api/watchlist", "", nil), http.StatusUnauthorized)
+		expectStatus(t, api.serve(http.MethodGet, "/api/watchlist", "not-a-token", nil), http.StatusUnauthorized)
+	})
+}
+
+func TestWatchlist(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		token := api.register("alice@example.com")
+
+		first := api.add(token, 11)
+		second := api.add(token, 12)
+		third := api.add(token, 13)
+		expectIDs(t, api.watchlist(token), first.ID, second.ID, third.ID)
+
+		moves := gin.H{"moves": []gin.H{{"id": third.ID, "after_id": 0}, {"id": first.ID, "after_id": second.ID}}}
+		expectStatus(t, api.serve(http.MethodPost, "/api/watchlist/reorder", token, moves), http.StatusOK)
+		expectIDs(t, api.watchlist(token), third.ID, second.ID, first.ID)
+
+		// Only the moved entries are written
+		for _, movie := range api.entries(token) {
+			if movie.ID == second.ID && movie.Version != second.Version {
+				t.Fatalf("reorder moved entry %d from version %d to %d", movie.ID, second.Version, movie.Version)
+			}
+		}
+
+		expectStatus(t, api.serve(http.MethodDelete, fmt.Sprint("/api/watchlist/", second.ID), token, nil), http.StatusNoContent)
+		expectIDs(t, api.watchlist(token), third.ID, first.ID)
+
+		w := api.serve(http.MethodGet, "/api/trash", token, nil)
+		expectStatus(t, w, http.StatusOK)
+		var trash []models.Movie
+		decode(t, w, &trash)
+		if len(trash) != 1 || trash[0].ID != second.ID {
+			t.Fatalf("trash holds %+v, want entry %d", trash, second.ID)
+		}
+
+		expectStatus(t, api.serve(http.MethodPost, fmt.Sprint("/api/trash/", second.ID, "/restore"), token, nil), http.StatusOK)
+		expectIDs(t, api.watchlist(token), third.ID, second.ID, first.ID)
+
+		// Restoring again finds nothing in the trash
+		expectStatus(t, api.serve(http.MethodPost, fmt.Sprint("/api/trash/", second.ID, "/restore"), token, nil), http.StatusConflict)
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		token := api.register("alice@example.com")
+		api.add(token, 11)
+
+		w := api.serve(http.MethodGet, "/api/watchlist", token, nil)
+		expectStatus(t, w, http.StatusOK)
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("watchlist has no ETag")
+		}
+
+		expectStatus(t, api.serve(http.MethodGet, "/api/watchlist", token, nil, "If-None-Match", etag), http.StatusNotModified)
+
+		// A write moves the library to a new version
+		w = api.serve(http.MethodPost, "/api/watchlist", token, gin.H{"title": "Movie 12", "movie_id": 12}, "If-Match", etag)
+		expectStatus(t, w, http.StatusCreated)
+		current := w.Header().Get("ETag")
+		if current == "" || current == etag {
+			t.Fatalf("write answered ETag %q after %q", current, etag)
+		}
+
+		expectStatus(t, api.serve(http.MethodGet, "/api/watchlist", token, nil, "If-None-Match", etag), http.StatusOK)
+
+		w = api.serve(http.MethodPost, "/api/watchlist", token, gin.H{"title": "Movie 13", "movie_id": 13}, "If-Match", etag)
+		expectStatus(t, w, http.StatusPreconditionFailed)
+		if got := w.Header().Get("ETag"); got != current {
+			t.Fatalf("412 answered ETag %q, want %q", got, current)
+		}
+		if entries := api.watchlist(token); len(entries) != 2 {
+			t.Fatalf("refused write changed the watchlist to %v", entries)
+		}
+	})
+}
+
+func TestOtherUsersEntriesAreForbidden(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		alice := api.register("alice@example.com")
+		bob := api.register("bob@example.com")
+
+		movie := api.add(alice, 11)
+		trashed := api.add(alice, 12)
+		expectStatus(t, api.serve(http.MethodDelete, fmt.Sprint("/api/watchlist/", trashed.ID), alice, nil), http.StatusNoContent)
+
+		requests := []struct {
+			method string
+			path   string
+			body   interface{}
+		}{
+			{http.MethodDelete, fmt.Sprint("/api/watchlist/", movie.ID), nil},
+			{http.MethodPost, fmt.Sprint("/api/movies/mark/downloaded/", movie.ID), nil},
+			{http.MethodPost, fmt.Sprint("/api/movies/mark/watched/", movie.ID), nil},
+			{http.MethodPost, fmt.Sprint("/api/movies/rate/", movie.ID), gin.H{"rating": 4}},
+			{http.MethodPost, "/api/watchlist/reorder", gin.H{"moves": []gin.H{{"id": movie.ID, "after_id": 0}}}},
+			{http.MethodPost, fmt.Sprint("/api/trash/", trashed.ID, "/restore"), nil},
+		}
+
+		for _, request := range requests {
+			w := api.serve(request.method, request.path, bob, request.body)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("%s %s as another user: got %d, want 403: %s", request.method, request.path, w.Code, w.Body)
+			}
+		}
+
+		expectIDs(t, api.watchlist(alice), movie.ID)
+		expectIDs(t, api.watchlist(bob))
+	})
+}
+
+func TestInjectedEndpoints(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		token := api.register("alice@example.com")
+		api.add(token, 11)
+
+		requests := []struct {
+			method string
+			path   string
+			body   interface{}
+		}{
+			{http.MethodGet, "/api/user/preferences", nil},
+			{http.MethodPut, "/api/user/preferences", gin.H{"regions": []string{"gr"}, "language": "el-GR"}},
+			{http.MethodGet, "/api/activity", nil},
+			{http.MethodGet, "/api/stats", nil},
+			{http.MethodGet, "/api/export?format=json", nil},
+			{http.MethodGet, "/api/library/search?q=movie", nil},
+			{http.MethodGet, "/api/sync?since=0", nil},
+			{http.MethodPost, "/api/sync", gin.H{"mutations": []gin.H{{"op": "add", "movie_id": 12, "title": "Movie 12"}}}},
+			{http.MethodGet, "/api/popular", nil},
+			{http.MethodGet, "/api/series", nil},
+		}
+
+		for _, request := range requests {
+			w := api.serve(request.method, request.path, token, request.body)
+			if w.Code != http.StatusOK {
+				t.Errorf("%s %s: got %d, want 200: %s", request.method, request.path, w.Code, w.Body)
+			}
+		}
+	})
+}
+
+func TestStaleVersionConflicts(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		token := api.register("alice@example.com")
+
+		movie := api.add(token, 11)
+		other := api.add(token, 12)
+		read := movie.Version
+
+		path := fmt.Sprint("/api/movies/rate/", movie.ID)
+		expectStatus(t, api.serve(http.MethodPost, path, token, gin.H{"rating": 4, "version": read}), http.StatusNoContent)
+
+		// Every write made on the version read before the rating conflicts
+		requests := []struct {
+			method string
+			path   string
+			body   interface{}
+		}{
+			{http.MethodPost, path, gin.H{"rating": 2, "version": read}},
+			{http.MethodPost, fmt.Sprint("/api/movies/mark/downloaded/", movie.ID), gin.H{"version": read}},
+			{http.MethodPost, fmt.Sprint("/api/movies/mark/watched/", movie.ID, "?version=", read), nil},
+			{http.MethodDelete, fmt.Sprint("/api/watchlist/", movie.ID, "?version=", read), nil},
+			{http.MethodPost, "/api/watchlist/reorder", gin.H{"moves": []gin.H{{"id": movie.ID, "after_id": other.ID, "version": read}}}},
+		}
+
+		for _, request := range requests {
+			w := api.serve(request.method, request.path, token, request.body)
+			if w.Code != http.StatusConflict {
+				t.Errorf("%s %s on a stale version: got %d, want 409: %s", request.method, request.path, w.Code, w.Body)
+			}
+		}
+		expectIDs(t, api.watchlist(token), movie.ID, other.ID)
+
+		current := read + 1
+		expectStatus(t, api.serve(http.MethodDelete, fmt.Sprint("/api/watchlist/", movie.ID, "?version=", current), token, nil), http.StatusNoContent)
+
+		restore := fmt.Sprint("/api/trash/", movie.ID, "/restore")
+		expectStatus(t, api.serve(http.MethodPost, restore, token, gin.H{"version": current}), http.StatusConflict)
+		expectStatus(t, api.serve(http.MethodPost, restore, token, gin.H{"version": current + 1}), http.StatusOK)
+	})
+}
+
+func TestPopularOnlyServesSupportedLanguagesAndRegions(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?language=EN-us&region=GR", "", nil), http.StatusOK)
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?language=cy-GB", "", nil), http.StatusBadRequest)
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?region=AQ", "", nil), http.StatusBadRequest)
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?region=gr", "", nil), http.StatusOK)
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?region=aq", "", nil), http.StatusBadRequest)
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?region=G1", "", nil), http.StatusBadRequest)
+
+		t.Setenv("POPULAR_LANGUAGES", "cy-gb")
+		expectStatus(t, api.serve(http.MethodGet, "/api/popular?language=cy-GB", "", nil), http.StatusOK)
+	})
+}
+
+// unreachable fails the popular list like a provider that cannot be reached
+type unreachable struct {
+	*providers.Fake
+}
+
+func (unreachable) PopularMovies(providers.ListOptions) (*providers.MovieList, error) {
+	return nil, errors.New("provider unreachable")
+}
+
+func TestPopularReportsProviderFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	metadata := unreachable{providers.NewFake()}
+	h := controllers.NewHandler(repository.NewMemoryUsers(), repository.NewMemoryMovies(), repository.NewMemorySeries(metadata), metadata)
+	api := &testAPI{t: t, engine: New(h)}
+
+	expectStatus(t, api.serve(http.MethodGet, "/api/popular", "", nil), http.StatusBadGateway)
+}
+
+func TestPreferencesKeepLanguageWhenLeftOut(t *testing.T) {
+	eachBackend(t, func(t *testing.T, api *testAPI) {
+		token := api.register("alice@example.com")
+
+		update := func(body gin.H) models.Preferences {
+			t.Helper()
+
+			w := api.serve(http.MethodPut, "/api/user/preferences", token, body)
+			expectStatus(t, w, http.StatusOK)
+
+			var prefs models.Preferences
+			decode(t, w, &prefs)
+			return prefs
+		}
+
+		if prefs := update(gin.H{"regions": []string{"GR"}, "language": "el-GR"}); prefs.Language != "el-GR" {
+			t.Fatalf("language is %q after setting el-GR", prefs.Language)
+		}
+
+		prefs := update(gin.H{"regions": []string{"US"}})
+		if prefs.Language != "el-GR" || fmt.Sprint(prefs.Regions) != "[US]" {
+			t.Fatalf("got %+v after updating the regions only", prefs)
+		}
+
+		if prefs := update(gin.H{"regions": []string{"US"}, "language": ""}); prefs.Language != "en-US" {
+			t.Fatalf("language is %q after clearing it, want the default", prefs.Language)
+		}
+
+		w := api.serve(http.MethodPut, "/api/user/preferences", token, gin.H{"language": "not a language"})
+		expectStatus(t, w, http.StatusBadRequest)
+	})
+}
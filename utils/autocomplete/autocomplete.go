@@ -0,0 +1,162 @@
+// Package autocomplete suggests movies while the user types a title
+package autocomplete
+
+import (
+	"fmt"
+	"movies-backend/providers"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/patrickmn/go-cache"
+	"golang.org/x/text/unicode/norm"
+)
+
+const posterURL = "https://image.tmdb.org/t/p/w92"
+
+// Shortest prefix whose results are reused for longer terms
+const minPrefixLength = 2
+
+// Earliest year taken as a year hint rather than part of the title
+const firstMovieYear = 1870
+
+// Suggestion is a movie matching the typed term. Year is 0 when the release date is unknown.
+type Suggestion struct {
+	ID            uint   `json:"id"`
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	Year          int    `json:"year,omitempty"`
+	PosterURL     string `json:"poster_url"`
+}
+
+// prefixResults are the results of a search kept to answer the terms extending it
+type prefixResults struct {
+	results  []providers.MovieSummary
+	complete bool
+}
+
+// Results of the terms typed recently, keyed by language, year hint and term
+var prefixCache = cache.New(time.Hour, 10*time.Minute)
+
+// normalize lower cases the term and collapses its spaces
+func normalize(term string) string {
+	return strings.Join(strings.Fields(strings.ToLower(term)), " ")
+}
+
+// ParseYearHint splits a trailing year off the term, "dune 2021" searches "dune" released in
+// 2021. A term that is only a year is a title.
+func ParseYearHint(term string) (string, int) {
+	words := strings.Fields(term)
+	if len(words) < 2 {
+		return term, 0
+	}
+
+	last := words[len(words)-1]
+	year, err := strconv.Atoi(last)
+	if err != nil || len(last) != 4 || year < firstMovieYear || year > time.Now().Year()+5 {
+		return term, 0
+	}
+
+	return strings.Join(words[:len(words)-1], " "), year
+}
+
+func cacheKey(language string, year int, term string) string {
+	return fmt.Sprintf("%s:%d:%s", language, year, term)
+}
+
+// fold lower cases the text, strips accents and turns punctuation into spaces so titles compare
+// like the provider search does, "ame" and "amé" both find "Amélie"
+func fold(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop the accents split off by NFD
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func matches(movie providers.MovieSummary, term string) bool {
+	return strings.Contains(fold(movie.Title), term) || strings.Contains(fold(movie.OriginalTitle), term)
+}
+
+// fromPrefix answers from the cached results of the term, or else of its longest typed prefix.
+// Those are enough when they still hold the requested number of matches, or when they were complete.
+func fromPrefix(language string, year int, term string, limit int) ([]providers.MovieSummary, bool) {
+	runes := []rune(term)
+	for length := len(runes); length >= minPrefixLength; length-- {
+		cached, found := prefixCache.Get(cacheKey(language, year, string(runes[:length])))
+		if !found {
+			continue
+		}
+
+		prefix := cached.(prefixResults)
+		if length == len(runes) {
+			// The same term was searched already
+			return prefix.results, true
+		}
+
+		folded := fold(term)
+		var results []providers.MovieSummary
+		for _, movie := range prefix.results {
+			if matches(movie, folded) {
+				results = append(results, movie)
+			}
+		}
+
+		if len(results) >= limit || prefix.complete {
+			return results, true
+		}
+		return nil, false
+	}
+
+	return nil, false
+}
+
+// Suggest returns at most limit movies matching the term, titles localized in the language
+func Suggest(metadata providers.Provider, term string, limit int, language string) ([]Suggestion, error) {
+	title, year := ParseYearHint(term)
+	title = normalize(title)
+
+	results, found := fromPrefix(language, year, title, limit)
+	if !found {
+		list, err := metadata.SearchMovies(title, providers.SearchOptions{Language: language, Page: 1, Year: year})
+		if err != nil {
+			return nil, err
+		}
+		if list == nil {
+			// Providers may answer without a list when nothing matches
+			list = &providers.MovieList{}
+		}
+
+		results = list.Results
+		prefixCache.Set(cacheKey(language, year, title), prefixResults{results: results, complete: list.TotalResults <= int64(len(results))}, cache.DefaultExpiration)
+	}
+
+	suggestions := []Suggestion{}
+	for _, movie := range results {
+		if len(suggestions) == limit {
+			break
+		}
+		if movie.ID == 0 {
+			continue
+		}
+
+		suggestion := Suggestion{ID: movie.ID, Title: movie.Title, OriginalTitle: movie.OriginalTitle}
+		if len(movie.ReleaseDate) >= 4 {
+			suggestion.Year, _ = strconv.Atoi(movie.ReleaseDate[:4])
+		}
+		if movie.PosterPath != "" {
+			suggestion.PosterURL = posterURL + movie.PosterPath
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,155 @@
+package autocomplete
+
+import (
+	"fmt"
+	"movies-backend/providers"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseYearHint(t *testing.T) {
+	tests := []struct {
+		term  string
+		title string
+		year  int
+	}{
+		{"dune 2021", "dune", 2021},
+		{"the  thing 1982 ", "the thing", 1982},
+		{"2001", "2001", 0},
+		{"dune", "dune", 0},
+		{"dune 1850", "dune 1850", 0},
+		{"dune 21", "dune 21", 0},
+		{"blade runner 2049", "blade runner 2049", 0},
+		{fmt.Sprint("avatar ", time.Now().Year()+1), "avatar", time.Now().Year() + 1},
+	}
+
+	for _, test := range tests {
+		title, year := ParseYearHint(test.term)
+		if title != test.title || year != test.year {
+			t.Errorf("ParseYearHint(%q) = %q, %d, want %q, %d", test.term, title, year, test.title, test.year)
+		}
+	}
+}
+
+// newFake returns a provider with the movies titled as given, the first one the most popular
+func newFake(t *testing.T, titles ...string) *providers.Fake {
+	prefixCache.Flush()
+	t.Cleanup(prefixCache.Flush)
+
+	fake := providers.NewFake()
+	for i, title := range titles {
+		movie := providers.MovieDetails{}
+		movie.ID = uint(i + 1)
+		movie.Title = title
+		movie.Popularity = float32(len(titles) - i)
+		fake.Add(movie)
+	}
+	return fake
+}
+
+func suggest(t *testing.T, fake *providers.Fake, term string, limit int) []string {
+	t.Helper()
+
+	suggestions, err := Suggest(fake, term, limit, "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles := []string{}
+	for _, suggestion := range suggestions {
+		titles = append(titles, suggestion.Title)
+	}
+	return titles
+}
+
+func expectTitles(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func expectSearches(t *testing.T, fake *providers.Fake, want int) {
+	t.Helper()
+
+	if got := fake.Calls("search"); got != want {
+		t.Fatalf("provider searched %d times, want %d", got, want)
+	}
+}
+
+func TestSuggestAnswersLongerTermsFromPrefix(t *testing.T) {
+	fake := newFake(t, "Dune", "Dunkirk", "Dumbo")
+
+	expectTitles(t, suggest(t, fake, "du", 10), "Dune", "Dunkirk", "Dumbo")
+	expectTitles(t, suggest(t, fake, "dun", 10), "Dune", "Dunkirk")
+	expectTitles(t, suggest(t, fake, "Dune", 10), "Dune")
+	expectSearches(t, fake, 1)
+}
+
+func TestSuggestAnswersRepeatedTermFromCache(t *testing.T) {
+	fake := newFake(t, "Dune", "Dunkirk")
+
+	expectTitles(t, suggest(t, fake, "dune", 10), "Dune")
+	expectTitles(t, suggest(t, fake, "dune", 10), "Dune")
+	expectSearches(t, fake, 1)
+}
+
+func TestSuggestFoldsAccentsOfCachedTitles(t *testing.T) {
+	fake := newFake(t, "Amélie", "Amadeus", "Spider-Man")
+
+	expectTitles(t, suggest(t, fake, "am", 10), "Amélie", "Amadeus")
+	expectTitles(t, suggest(t, fake, "ame", 10), "Amélie")
+	expectTitles(t, suggest(t, fake, "amé", 10), "Amélie")
+
+	expectTitles(t, suggest(t, fake, "sp", 10), "Spider-Man")
+	expectTitles(t, suggest(t, fake, "spider man", 10), "Spider-Man")
+	expectSearches(t, fake, 2)
+}
+
+func TestSuggestSearchesAgainWhenPrefixResultsRunShort(t *testing.T) {
+	// More matches than the single page of results kept for the prefix
+	titles := []string{}
+	for i := 1; i <= 25; i++ {
+		titles = append(titles, "Star "+strconv.Itoa(i))
+	}
+	fake := newFake(t, titles...)
+
+	suggest(t, fake, "st", 5)
+	expectSearches(t, fake, 1)
+
+	expectTitles(t, suggest(t, fake, "star 2", 5), "Star 2", "Star 20", "Star 21", "Star 22", "Star 23")
+	expectSearches(t, fake, 2)
+}
+
+func TestSuggestKeepsYearHintsApart(t *testing.T) {
+	fake := newFake(t, "Dune")
+
+	suggest(t, fake, "dune", 10)
+	suggest(t, fake, "dune 2021", 10)
+	expectSearches(t, fake, 2)
+}
+
+// silent answers every search with no list and no error
+type silent struct {
+	*providers.Fake
+}
+
+func (p silent) SearchMovies(query string, options providers.SearchOptions) (*providers.MovieList, error) {
+	p.Fake.SearchMovies(query, options)
+	return nil, nil
+}
+
+func TestSuggestTreatsMissingListAsNoResults(t *testing.T) {
+	fake := newFake(t, "Dune")
+
+	suggestions, err := Suggest(silent{fake}, "dune", 10, "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 0 {
+		t.Fatalf("got %+v, want no suggestions", suggestions)
+	}
+	expectSearches(t, fake, 1)
+}
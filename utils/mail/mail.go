@@ -10,6 +10,15 @@ import (
 )
 
 func SendMail(receiver string, movies []string) error {
+	return Send(receiver, "New movies available to watch", "The following movies are available to download:", movies)
+}
+
+func SendEpisodesMail(receiver string, episodes []string) error {
+	return Send(receiver, "New episodes available to watch", "The following episodes have aired:", episodes)
+}
+
+// Send sends a plain text notification listing one item per line after the intro
+func Send(receiver string, subject string, intro string, items []string) error {
 	m := gomail.NewMessage()
 
 	// Set E-Mail sender
@@ -19,10 +28,10 @@ func SendMail(receiver string, movies []string) error {
 	m.SetHeader("To", receiver)
 
 	// Set E-Mail subject
-	m.SetHeader("Subject", "New movies available to watch")
+	m.SetHeader("Subject", subject)
 
 	// Set E-Mail body. You can set plain text or html with text/html
-	m.SetBody("text/plain", "The following movies are available to download:\n"+strings.Join(movies, "\n"))
+	m.SetBody("text/plain", intro+"\n"+strings.Join(items, "\n"))
 
 	// Settings for SMTP server
 	port, err := strconv.Atoi(os.Getenv("EMAIL_PORT"))
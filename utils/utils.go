@@ -8,6 +8,7 @@ import (
 	"movies-backend/utils/mail"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +19,9 @@ const (
 	YYYYMMDD = "2006-01-02"
 )
 
+// Default number of hours before the releases of a movie not available yet are fetched again
+const defaultReleasesRefreshHours = 24
+
 func CheckForAvailableMovies() {
 	var users []models.User
 
@@ -26,20 +30,32 @@ func CheckForAvailableMovies() {
 		return
 	}
 
-	for _, user := range users {
-		// Find all movies without release release_date for each user
-		movies := models.GetMoviesWithoutReleaseDateByUserID(user.ID)
+	now := time.Now().UTC()
 
-		// Update release dates
-		for _, movie := range movies {
-			movie.UpdateReleaseDate()
-			_ = movie.UpdateMovie()
+	refreshHours := defaultReleasesRefreshHours
+	if hours, err := strconv.Atoi(os.Getenv("RELEASES_REFRESH_HOURS")); err == nil && hours > 0 {
+		refreshHours = hours
+	}
+
+	// Update release dates once per movie no matter how many users have it
+	films, err := models.GetFilmsNeedingReleases(now.Add(-time.Duration(refreshHours)*time.Hour), now)
+	if err != nil {
+		log.Println("Warning: Cannot get movies with stale release dates", err)
+	}
+	for _, film := range films {
+		film.UpdateReleaseDate()
+		if err := film.UpdateFilm(); err != nil {
+			log.Println("Error updating movie release dates", film.ID, err)
 		}
+	}
 
+	for _, user := range users {
 		// Get the movies that are available and we have not send an email notification
-		now := time.Now().UTC()
-		var availableMovies []models.Movie
-		models.DB.Where("release_date <= ? AND email_sent = ?", now.Format(YYYYMMDD), 0).Find(&availableMovies, "user_id = ?", user.ID)
+		availableMovies, err := models.GetMoviesToNotifyByUserID(user.ID, user.ReleasePreferences(), now)
+		if err != nil {
+			log.Println("Warning: Cannot get available movies for user", user.ID)
+			continue
+		}
 
 		if len(availableMovies) > 0 {
 			movieTitles := []string{}
@@ -49,12 +65,128 @@ func CheckForAvailableMovies() {
 
 			err := mail.SendMail(user.Email, movieTitles)
 			if err == nil {
-				for _, movie := range availableMovies {
-					movie.EmailSent = true
-					movie.UpdateMovie()
+				models.MarkMoviesAsEmailSent(availableMovies)
+			}
+		}
+	}
+}
+
+// Default number of days a deleted movie is kept in the trash
+const defaultTrashRetentionDays = 30
+
+func PurgeExpiredTrash() {
+	retentionDays := defaultTrashRetentionDays
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		retentionDays = days
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -retentionDays)
+	purged, err := models.PurgeTrash(cutoff)
+	if err != nil {
+		log.Println("Warning: Cannot purge trash", err)
+		return
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d movies from trash", purged)
+	}
+}
+
+// PurgeExpiredProviderResponses deletes the expired responses persisted by the metadata cache
+func PurgeExpiredProviderResponses() {
+	purged, err := models.PurgeProviderResponses(time.Now().UTC())
+	if err != nil {
+		log.Println("Warning: Cannot purge cached metadata", err)
+		return
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d cached metadata responses", purged)
+	}
+}
+
+// Default number of days before the metadata of a movie is fetched again from TMDb
+const defaultMetadataRefreshDays = 7
+
+// Maximum number of movies whose metadata is refreshed in one scheduler run
+const metadataRefreshBatch = 100
+
+func RefreshStaleMetadata() {
+	refreshDays := defaultMetadataRefreshDays
+	if days, err := strconv.Atoi(os.Getenv("METADATA_REFRESH_DAYS")); err == nil && days > 0 {
+		refreshDays = days
+	}
+
+	now := time.Now().UTC()
+	ids, err := models.GetMovieIDsNeedingMetadata(now.AddDate(0, 0, -refreshDays), now, metadataRefreshBatch)
+	if err != nil {
+		log.Println("Warning: Cannot get movies with stale metadata", err)
+		return
+	}
+
+	for _, id := range ids {
+		if _, err := models.RefreshMovieMetadata(id); err != nil {
+			log.Println("Error refreshing movie metadata", id, err)
+		}
+	}
+}
+
+// Default number of hours before a series still airing is fetched again from TMDb
+const defaultSeriesRefreshHours = 24
+
+// CheckForNewEpisodes refreshes the tracked series still airing and notifies every user about
+// the episodes aired since their last notification
+func CheckForNewEpisodes() {
+	refreshHours := defaultSeriesRefreshHours
+	if hours, err := strconv.Atoi(os.Getenv("SERIES_REFRESH_HOURS")); err == nil && hours > 0 {
+		refreshHours = hours
+	}
+
+	now := time.Now().UTC()
+
+	ids, err := models.GetSeriesIDsNeedingRefresh(now.Add(-time.Duration(refreshHours) * time.Hour))
+	if err != nil {
+		log.Println("Warning: Cannot get series to refresh", err)
+	}
+
+	for _, id := range ids {
+		if _, err := models.RefreshSeries(id); err != nil {
+			log.Println("Error refreshing series", id, err)
+		}
+	}
+
+	var users []models.User
+
+	if err := models.DB.Find(&users).Error; err != nil {
+		log.Println("Warning: Cannot get users from DB")
+		return
+	}
+
+	for _, user := range users {
+		tracked, err := models.GetSeriesToNotifyByUserID(user.ID, now)
+		if err != nil {
+			log.Println("Warning: Cannot get new episodes for user", user.ID)
+			continue
+		}
+
+		if len(tracked) == 0 {
+			continue
+		}
+
+		episodeTitles := []string{}
+		for _, userSeries := range tracked {
+			for _, episode := range userSeries.NewEpisodes {
+				title := fmt.Sprintf("%s S%02dE%02d %s", userSeries.Series.Name, episode.SeasonNumber, episode.EpisodeNumber, episode.Name)
+				if episode.EpisodeNumber == 1 {
+					title += " (new season)"
 				}
+				episodeTitles = append(episodeTitles, title)
 			}
 		}
+
+		if err := mail.SendEpisodesMail(user.Email, episodeTitles); err == nil {
+			_ = models.MarkSeriesAsNotified(tracked, now)
+		}
 	}
 }
 
@@ -76,14 +208,15 @@ func TriggerModelRetrain() {
 	// Make GET request to suggestions API
 	resp, err := http.Get(os.Getenv("MOVIES_ML_BASE_URL") + "/train")
 	if err != nil {
-		log.Println("failed to call train API: %w", err)
+		log.Printf("failed to call train API: %v", err)
+		return
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("failed to read response body: %w", err)
+		log.Printf("failed to read response body: %v", err)
 	}
 
 	// Check response status